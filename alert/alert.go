@@ -0,0 +1,467 @@
+package alert
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+// Config is a configuration of alert states on the settings file.
+type Config struct {
+	Path               string        // path to the database file of alert states, states are not tracked if empty
+	RepeatInterval     time.Duration // interval to notify firing alerts again, never if zero
+	MaintenanceWindows []MaintenanceWindow
+	Correlation        Correlation
+}
+
+// State is a state of an alert for a rule of a table.
+type State string
+
+const (
+	StateOK           State = "ok"
+	StateFiring       State = "firing"
+	StateAcknowledged State = "acknowledged"
+	StateResolved     State = "resolved"
+)
+
+// Alert is a state of a rule of a table config.
+type Alert struct {
+	Table        string    `json:"table"` // key of the table config, see config.FreshnessResult.Key
+	Rule         string    `json:"rule"`
+	State        State     `json:"state"`
+	Since        time.Time `json:"since"` // time when the state changed
+	LastNotified time.Time `json:"last_notified,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	StaleSince   time.Time `json:"stale_since,omitempty"` // time when the rule was first violated in a row
+	StaleCount   int       `json:"stale_count,omitempty"` // number of consecutive violations
+	FreshCount   int       `json:"fresh_count,omitempty"` // number of consecutive non-violations while firing
+}
+
+func (a Alert) key() []byte {
+	return []byte(a.Table + ":" + a.Rule)
+}
+
+var alertBucket = []byte("alerts")
+
+// Store is an embedded database of alert states.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the alert store on path, creating it if it doesn't exist.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open alert store %s: %w", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(alertBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, xerrors.Errorf("failed to initialize alert store: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Transition is pending changes of alert states by a report.
+// The states are saved by Commit after the changes are notified, so that alerts are notified again if it fails.
+type Transition struct {
+	// Changes is a report which should be notified.
+	// It contains results of tables whose alerts start firing or should be notified again as Results,
+	// and results of tables which became fresh as Recovered.
+	Changes config.Report
+	// Report is the updated report whose violations are rules of firing alerts,
+	// which is sent to notifiers deduplicating alerts by themselves, so that they follow ConsecutiveStale and ConsecutiveFresh too.
+	Report config.Report
+
+	store   *Store
+	pending []pendingAlert
+}
+
+// pendingAlert is a changed state of an alert, and the state to save instead if its notification fails.
+type pendingAlert struct {
+	next        Alert
+	undelivered Alert
+}
+
+// Update transits alert states by the report, and returns the transition whose changes should be notified.
+// The states are not saved until the transition is committed.
+// Results which failed to be checked or are silenced don't change the states.
+// Alerts of tables collapsed into an incident only count violations without firing,
+// and tables of the incident which are still violated are notified again when it clears.
+func (s *Store) Update(report config.Report, repeatInterval time.Duration) (*Transition, error) {
+	t := &Transition{
+		Changes: config.Report{CheckedAt: report.CheckedAt, Results: make([]config.FreshnessResult, 0), Totals: report.Totals},
+		Report:  report,
+		store:   s,
+	}
+	t.Report.Results = make([]config.FreshnessResult, 0, len(report.Results))
+
+	var cleared []config.FreshnessResult
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(alertBucket)
+		for _, r := range report.Results {
+			if r.Status == config.StatusError || r.Silenced() {
+				t.Report.Results = append(t.Report.Results, r)
+				continue
+			}
+
+			states, notify, recovered, err := t.transitResult(b, r, report.CheckedAt, repeatInterval)
+			if err != nil {
+				return err
+			}
+			for _, m := range r.Members {
+				if err := t.countResult(b, m, report.CheckedAt); err != nil {
+					return err
+				}
+			}
+
+			t.Report.Results = append(t.Report.Results, debounce(r, states))
+			if notify {
+				t.Changes.Results = append(t.Changes.Results, r)
+			} else if recovered && r.Status == config.StatusFresh {
+				t.Changes.Recovered = append(t.Changes.Recovered, r)
+				if r.IsGroup() {
+					cleared = append(cleared, r)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range cleared {
+		t.notifyRemaining(report, g)
+	}
+	return t, nil
+}
+
+// notifyRemaining adds tables of the cleared incident which are still violated to the changes,
+// since their alerts may have been covered by the incident.
+func (t *Transition) notifyRemaining(report config.Report, incident config.FreshnessResult) {
+	notified := make(map[string]bool, len(t.Changes.Results))
+	for _, r := range t.Changes.Results {
+		notified[r.Key()] = true
+	}
+	key := correlationKey(incident, incident.Group)
+	for _, r := range report.Results {
+		if r.IsGroup() || r.Status == config.StatusError || r.Silenced() || len(r.Violations) == 0 {
+			continue
+		}
+		if correlationKey(r, incident.Group) == key && !notified[r.Key()] {
+			t.Changes.Results = append(t.Changes.Results, r)
+			notified[r.Key()] = true
+		}
+	}
+}
+
+// transitResult adds the changed alert states of the result to the transition,
+// and returns the next states of all rules, and whether alerts start firing or are resolved.
+func (t *Transition) transitResult(b *bolt.Bucket, r config.FreshnessResult, current time.Time, repeatInterval time.Duration) (states map[string]Alert, notify, recovered bool, err error) {
+	violated := make(map[string]config.Violation, len(r.Violations))
+	for _, v := range r.Violations {
+		violated[v.Rule] = v
+	}
+
+	states = make(map[string]Alert, len(config.Rules))
+	for _, rule := range config.Rules {
+		prev, err := get(b, Alert{Table: r.Key(), Rule: rule}.key())
+		if err != nil {
+			return nil, false, false, err
+		}
+		if prev == nil {
+			prev = &Alert{Table: r.Key(), Rule: rule, State: StateOK}
+		}
+
+		v, ok := violated[rule]
+		p := policy{
+			repeatInterval:   repeatInterval,
+			consecutiveStale: r.ConsecutiveStale,
+			consecutiveFresh: r.ConsecutiveFresh,
+		}
+		next, n := transit(*prev, ok, v.Reason, current, p)
+		states[rule] = next
+		if next == *prev {
+			continue
+		}
+		undelivered := next
+		if n {
+			undelivered = count(*prev, ok, v.Reason, current)
+		}
+		t.pending = append(t.pending, pendingAlert{next: next, undelivered: undelivered})
+
+		switch {
+		case n && next.State == StateFiring:
+			notify = true
+		case n && next.State == StateResolved:
+			recovered = true
+		}
+	}
+	return states, notify, recovered, nil
+}
+
+// countResult adds the alert states of the result whose numbers of consecutive violations and non-violations are updated,
+// without firing or resolving them.
+func (t *Transition) countResult(b *bolt.Bucket, r config.FreshnessResult, current time.Time) error {
+	violated := make(map[string]config.Violation, len(r.Violations))
+	for _, v := range r.Violations {
+		violated[v.Rule] = v
+	}
+
+	for _, rule := range config.Rules {
+		prev, err := get(b, Alert{Table: r.Key(), Rule: rule}.key())
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			prev = &Alert{Table: r.Key(), Rule: rule, State: StateOK}
+		}
+
+		v, ok := violated[rule]
+		if next := count(*prev, ok, v.Reason, current); next != *prev {
+			t.pending = append(t.pending, pendingAlert{next: next, undelivered: next})
+		}
+	}
+	return nil
+}
+
+// debounce returns the result whose violations are rules of firing or acknowledged alerts.
+// Violations whose alerts don't fire yet are removed, and rules whose alerts are not resolved yet are still violated.
+// StaleSince of the result is the earliest one of the alerts.
+func debounce(r config.FreshnessResult, states map[string]Alert) config.FreshnessResult {
+	active := func(rule string) bool {
+		s := states[rule].State
+		return s == StateFiring || s == StateAcknowledged
+	}
+
+	var vs []config.Violation
+	violated := make(map[string]bool, len(r.Violations))
+	for _, v := range r.Violations {
+		violated[v.Rule] = true
+		if active(v.Rule) {
+			vs = append(vs, v)
+		}
+	}
+	for _, rule := range config.Rules {
+		if !violated[rule] && active(rule) {
+			vs = append(vs, config.Violation{Rule: rule, Reason: states[rule].Reason, Severity: r.RuleSeverity(rule)})
+		}
+	}
+
+	r.Violations = vs
+	r.Reason = nil
+	r.Status = config.StatusFresh
+	for _, v := range vs {
+		if since := states[v.Rule].StaleSince; !since.IsZero() && (r.StaleSince.IsZero() || since.Before(r.StaleSince)) {
+			r.StaleSince = since
+		}
+		r.Reason = append(r.Reason, v.Reason)
+		switch {
+		case v.Rule == config.RuleExists && !r.IsGroup():
+			r.Status = config.StatusMissing
+		case r.Status == config.StatusFresh:
+			r.Status = config.StatusStale
+		}
+	}
+	return r
+}
+
+// Commit saves the alert states of the transition.
+// If delivered is false, alerts which should have been notified keep their states and are notified again on the next update,
+// while the numbers of consecutive violations and non-violations are saved.
+// The states are shared by all notifiers, so the alerts are notified again to the notifiers which succeeded as well.
+func (t *Transition) Commit(delivered bool) error {
+	return t.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(alertBucket)
+		for _, p := range t.pending {
+			a := p.next
+			if !delivered {
+				a = p.undelivered
+			}
+			if err := put(b, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// policy decides when alerts fire, resolve and are notified again.
+type policy struct {
+	repeatInterval   time.Duration
+	consecutiveStale int // number of consecutive violations to fire
+	consecutiveFresh int // number of consecutive non-violations to resolve
+}
+
+// count returns the alert whose numbers of consecutive violations and non-violations are updated.
+// StaleSince is kept while the alert is firing, even if the rule is not violated on some runs before it is resolved.
+func count(prev Alert, violated bool, reason string, current time.Time) Alert {
+	next := prev
+	if violated {
+		if prev.StaleCount == 0 && (prev.State == StateOK || prev.State == StateResolved || prev.StaleSince.IsZero()) {
+			next.StaleSince = current
+		}
+		next.Reason = reason
+		next.StaleCount++
+		next.FreshCount = 0
+	} else {
+		next.StaleCount = 0
+		if prev.State != StateOK && prev.State != StateResolved {
+			next.FreshCount++
+		}
+	}
+	return next
+}
+
+// transit returns the next state of the alert, and whether it should be notified.
+func transit(prev Alert, violated bool, reason string, current time.Time, p policy) (next Alert, notify bool) {
+	next = count(prev, violated, reason, current)
+
+	switch {
+	case violated && (prev.State == StateOK || prev.State == StateResolved):
+		if next.StaleCount < p.consecutiveStale {
+			return next, false
+		}
+		next.State = StateFiring
+		next.Since = current
+		next.LastNotified = current
+		return next, true
+	case violated && prev.State == StateFiring:
+		if p.repeatInterval > 0 && current.Sub(prev.LastNotified) >= p.repeatInterval {
+			next.LastNotified = current
+			return next, true
+		}
+		return next, false
+	case !violated && (prev.State == StateFiring || prev.State == StateAcknowledged):
+		if next.FreshCount < p.consecutiveFresh {
+			return next, false
+		}
+		next.State = StateResolved
+		next.Since = current
+		next.LastNotified = current
+		next.FreshCount = 0
+		return next, true
+	default: // acknowledged alerts keep silent until resolved
+		return next, false
+	}
+}
+
+// Acknowledge changes states of firing alerts of the table config to acknowledged,
+// and returns the acknowledged alerts. If rule is empty, alerts of all rules are acknowledged.
+func (s *Store) Acknowledge(table, rule string, current time.Time) (acked []Alert, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(alertBucket)
+		for _, rl := range config.Rules {
+			if rule != "" && rule != rl {
+				continue
+			}
+			a, err := get(b, Alert{Table: table, Rule: rl}.key())
+			if err != nil {
+				return err
+			}
+			if a == nil || a.State != StateFiring {
+				continue
+			}
+
+			a.State = StateAcknowledged
+			a.Since = current
+			if err := put(b, *a); err != nil {
+				return err
+			}
+			acked = append(acked, *a)
+		}
+		return nil
+	})
+	return acked, err
+}
+
+// ActiveTables returns keys of table configs with the prefix which have alerts firing, acknowledged,
+// or counting consecutive violations, so that they are settled by a result without violations.
+func (s *Store) ActiveTables(prefix string) (map[string]bool, error) {
+	alerts, err := s.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]bool)
+	for _, a := range alerts {
+		if a.State == StateFiring || a.State == StateAcknowledged || a.StaleCount > 0 {
+			active[a.Table] = true
+		}
+	}
+	return active, nil
+}
+
+// List returns alerts whose table config key has the prefix.
+func (s *Store) List(prefix string) (alerts []Alert, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(alertBucket).Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			var a Alert
+			if err := json.Unmarshal(v, &a); err != nil {
+				return xerrors.Errorf("failed to decode alert: %w", err)
+			}
+			alerts = append(alerts, a)
+		}
+		return nil
+	})
+	return alerts, err
+}
+
+func get(b *bolt.Bucket, key []byte) (*Alert, error) {
+	v := b.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	var a Alert
+	if err := json.Unmarshal(v, &a); err != nil {
+		return nil, xerrors.Errorf("failed to decode alert: %w", err)
+	}
+	return &a, nil
+}
+
+func put(b *bolt.Bucket, a Alert) error {
+	v, err := json.Marshal(a)
+	if err != nil {
+		return xerrors.Errorf("failed to encode alert: %w", err)
+	}
+	return b.Put(a.key(), v)
+}
+
+var batchBucket = []byte("batches")
+
+// LoadBatch returns deferred notifications of the notifier, or nil if there is none.
+func (s *Store) LoadBatch(name string) (v []byte, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(batchBucket)
+		if b == nil {
+			return nil
+		}
+		if stored := b.Get([]byte(name)); stored != nil {
+			v = append([]byte{}, stored...)
+		}
+		return nil
+	})
+	return v, err
+}
+
+// SaveBatch stores deferred notifications of the notifier.
+func (s *Store) SaveBatch(name string, v []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(batchBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(name), v)
+	})
+}
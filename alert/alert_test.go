@@ -0,0 +1,220 @@
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransit(t *testing.T) {
+	current := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	lastNotified := current.Add(-time.Hour)
+
+	tests := map[string]struct {
+		prev       State
+		staleCount int
+		freshCount int
+		violated   bool
+		p          policy
+
+		// output
+		next   State
+		notify bool
+	}{
+		"ok -> firing":                    {prev: StateOK, violated: true, next: StateFiring, notify: true},
+		"ok -> ok":                        {prev: StateOK, violated: false, next: StateOK, notify: false},
+		"firing -> firing":                {prev: StateFiring, violated: true, next: StateFiring, notify: false},
+		"firing -> firing, repeat":        {prev: StateFiring, violated: true, p: policy{repeatInterval: time.Hour}, next: StateFiring, notify: true},
+		"firing -> firing, before repeat": {prev: StateFiring, violated: true, p: policy{repeatInterval: 2 * time.Hour}, next: StateFiring, notify: false},
+		"firing -> resolved":              {prev: StateFiring, violated: false, next: StateResolved, notify: true},
+		"acknowledged -> acknowledged":    {prev: StateAcknowledged, violated: true, p: policy{repeatInterval: time.Hour}, next: StateAcknowledged, notify: false},
+		"acknowledged -> resolved":        {prev: StateAcknowledged, violated: false, next: StateResolved, notify: true},
+		"resolved -> firing":              {prev: StateResolved, violated: true, next: StateFiring, notify: true},
+		"resolved -> resolved":            {prev: StateResolved, violated: false, next: StateResolved, notify: false},
+		"ok -> ok, before consecutive stale": {
+			prev: StateOK, staleCount: 1, violated: true, p: policy{consecutiveStale: 3}, next: StateOK, notify: false,
+		},
+		"ok -> firing, consecutive stale": {
+			prev: StateOK, staleCount: 2, violated: true, p: policy{consecutiveStale: 3}, next: StateFiring, notify: true,
+		},
+		"firing -> firing, before consecutive fresh": {
+			prev: StateFiring, violated: false, p: policy{consecutiveFresh: 2}, next: StateFiring, notify: false,
+		},
+		"firing -> resolved, consecutive fresh": {
+			prev: StateFiring, freshCount: 1, violated: false, p: policy{consecutiveFresh: 2}, next: StateResolved, notify: true,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			prev := Alert{State: tt.prev, LastNotified: lastNotified, StaleCount: tt.staleCount, FreshCount: tt.freshCount}
+			next, notify := transit(prev, tt.violated, "reason", current, tt.p)
+			assert.Equal(t, tt.next, next.State)
+			assert.Equal(t, tt.notify, notify)
+		})
+	}
+}
+
+func result(status config.Status, rules ...string) config.FreshnessResult {
+	r := config.FreshnessResult{Project: "pj", Dataset: "ds", ConfigTable: "tbl", TableID: "tbl", Status: status}
+	for _, rule := range rules {
+		r.Violations = append(r.Violations, config.Violation{Rule: rule, Reason: rule + " is violated"})
+	}
+	return r
+}
+
+func TestStore_Update(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	base := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	steps := []struct {
+		result    config.FreshnessResult
+		notified  int
+		recovered int
+		states    map[string]State
+	}{
+		{result: result(config.StatusFresh), notified: 0, recovered: 0, states: map[string]State{}},
+		{
+			result:   result(config.StatusStale, config.RuleDurationThreshold),
+			notified: 1,
+			states:   map[string]State{config.RuleDurationThreshold: StateFiring},
+		},
+		{
+			result:   result(config.StatusStale, config.RuleDurationThreshold),
+			notified: 0,
+			states:   map[string]State{config.RuleDurationThreshold: StateFiring},
+		},
+		{
+			result:   result(config.StatusError),
+			notified: 0,
+			states:   map[string]State{config.RuleDurationThreshold: StateFiring},
+		},
+		{
+			result:   result(config.StatusStale, config.RuleDurationThreshold),
+			notified: 1, // repeat interval has passed
+			states:   map[string]State{config.RuleDurationThreshold: StateFiring},
+		},
+		{
+			result:    result(config.StatusFresh),
+			recovered: 1,
+			states:    map[string]State{config.RuleDurationThreshold: StateResolved},
+		},
+	}
+	for i, st := range steps {
+		report := config.Report{CheckedAt: base.Add(time.Duration(i) * time.Hour), Results: []config.FreshnessResult{st.result}}
+		tr, err := s.Update(report, 2*time.Hour)
+		assert.NoError(t, err)
+		assert.NoError(t, tr.Commit(true))
+		changes := tr.Changes
+		assert.Equal(t, st.notified, len(changes.Results), "step %d", i)
+		assert.Equal(t, st.recovered, len(changes.Recovered), "step %d", i)
+
+		alerts, err := s.List("pj.ds.tbl")
+		assert.NoError(t, err)
+		states := map[string]State{}
+		for _, a := range alerts {
+			states[a.Rule] = a.State
+		}
+		assert.Equal(t, st.states, states, "step %d", i)
+	}
+}
+
+func TestStore_Acknowledge(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	base := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	report := config.Report{CheckedAt: base, Results: []config.FreshnessResult{result(config.StatusStale, config.RuleDurationThreshold)}}
+	tr, err := s.Update(report, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, tr.Commit(true))
+
+	acked, err := s.Acknowledge("pj.ds.tbl", "", base)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(acked))
+	assert.Equal(t, StateAcknowledged, acked[0].State)
+
+	report.CheckedAt = base.Add(time.Hour)
+	tr, err = s.Update(report, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(tr.Changes.Results), "acknowledged alerts are not notified again")
+}
+
+func TestStore_UpdateFlapping(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	stale := result(config.StatusStale, config.RuleDurationThreshold)
+	stale.ConsecutiveStale = 2
+	fresh := result(config.StatusFresh)
+	fresh.ConsecutiveFresh = 2
+
+	base := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	steps := []struct {
+		result    config.FreshnessResult
+		notified  int
+		recovered int
+		status    config.Status // status of the debounced report
+	}{
+		{result: stale, status: config.StatusFresh},
+		{result: fresh, status: config.StatusFresh},
+		{result: stale, status: config.StatusFresh},
+		{result: stale, notified: 1, status: config.StatusStale},
+		{result: fresh, status: config.StatusStale},
+		{result: stale, status: config.StatusStale},
+		{result: fresh, status: config.StatusStale},
+		{result: fresh, recovered: 1, status: config.StatusFresh},
+	}
+	for i, st := range steps {
+		report := config.Report{CheckedAt: base.Add(time.Duration(i) * time.Hour), Results: []config.FreshnessResult{st.result}}
+		tr, err := s.Update(report, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, tr.Commit(true))
+		assert.Equal(t, st.notified, len(tr.Changes.Results), "step %d", i)
+		assert.Equal(t, st.recovered, len(tr.Changes.Recovered), "step %d", i)
+		assert.Equal(t, st.status, tr.Report.Results[0].Status, "step %d", i)
+		if st.status == config.StatusStale {
+			assert.Equal(t, []string{"duration_threshold is violated"}, tr.Report.Results[0].Reason, "step %d", i)
+			assert.Equal(t, base.Add(2*time.Hour), tr.Report.Results[0].StaleSince, "step %d: stale since the first violation in a row", i)
+		}
+	}
+}
+
+func TestTransition_CommitUndelivered(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	stale := result(config.StatusStale, config.RuleDurationThreshold)
+	stale.ConsecutiveStale = 2
+	fresh := result(config.StatusFresh)
+	base := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	steps := []struct {
+		result    config.FreshnessResult
+		delivered bool
+		notified  int
+		recovered int
+	}{
+		{result: stale, delivered: false},
+		{result: stale, delivered: false, notified: 1}, // consecutive violations are counted even if undelivered
+		{result: stale, delivered: true, notified: 1},  // notified again to all notifiers because any of them failed
+		{result: stale, delivered: true},
+		{result: fresh, delivered: false, recovered: 1},
+		{result: fresh, delivered: true, recovered: 1},
+		{result: fresh, delivered: true},
+	}
+	for i, st := range steps {
+		report := config.Report{CheckedAt: base.Add(time.Duration(i) * time.Hour), Results: []config.FreshnessResult{st.result}}
+		tr, err := s.Update(report, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, tr.Commit(st.delivered))
+		assert.Equal(t, st.notified, len(tr.Changes.Results), "step %d", i)
+		assert.Equal(t, st.recovered, len(tr.Changes.Recovered), "step %d", i)
+	}
+}
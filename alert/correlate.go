@@ -0,0 +1,191 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultCorrelationRatio     = 0.5
+	defaultCorrelationMinTables = 2
+)
+
+// Correlation is a configuration to collapse old tables of the same group into an incident.
+type Correlation struct {
+	GroupBy   string  // "dataset" or "owner", results are not correlated if empty
+	Ratio     float64 // minimum share of old tables in a group to collapse them (default: 0.5)
+	MinTables int     // minimum number of old tables in a group to collapse them (default: 2)
+}
+
+// correlationGroup is a group of results which may be caused by the same root cause.
+type correlationGroup struct {
+	key     string                   // project.dataset or owner
+	members []config.FreshnessResult // all results in the group
+	old     []config.FreshnessResult // results which can be collapsed
+}
+
+// Correlate returns the report whose old tables of the same group are collapsed into an incident,
+// when the share of them in the group reaches the ratio. The incident lists the tables as its members.
+// Groups without an incident whose key is active, see Store.ActiveTables, have a fresh result instead,
+// so that alerts of the incident are resolved.
+// Silenced tables and tables which failed to be checked are not collapsed.
+func Correlate(report config.Report, c Correlation, active map[string]bool) (config.Report, error) {
+	if c.GroupBy == "" {
+		return report, nil
+	}
+	if c.GroupBy != "dataset" && c.GroupBy != "owner" {
+		return config.Report{}, xerrors.Errorf("unknown group-by key of correlation: %s", c.GroupBy)
+	}
+	ratio := c.Ratio
+	if ratio == 0 {
+		ratio = defaultCorrelationRatio
+	}
+	minTables := c.MinTables
+	if minTables == 0 {
+		minTables = defaultCorrelationMinTables
+	}
+
+	groups := make(map[string]*correlationGroup)
+	order := make([]string, 0)
+	for _, r := range report.Results {
+		key := correlationKey(r, c.GroupBy)
+		if key == "" || r.IsGroup() {
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &correlationGroup{key: key}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.members = append(g.members, r)
+		if correlated(r) {
+			g.old = append(g.old, r)
+		}
+	}
+
+	incidents := make(map[string]*config.FreshnessResult)
+	for _, key := range order {
+		g := groups[key]
+		if len(g.old) >= minTables && float64(len(g.old)) >= ratio*float64(len(g.members)) {
+			incident := g.incident(c.GroupBy)
+			incidents[key] = &incident
+		}
+	}
+
+	results := make([]config.FreshnessResult, 0, len(report.Results))
+	for _, r := range report.Results {
+		key := correlationKey(r, c.GroupBy)
+		incident, ok := incidents[key]
+		if !ok || !correlated(r) || r.IsGroup() {
+			results = append(results, r)
+			continue
+		}
+		// the incident takes the place of its first member
+		if incident != nil {
+			results = append(results, *incident)
+			incidents[key] = nil
+		}
+	}
+	for _, key := range order {
+		if _, ok := incidents[key]; ok {
+			continue
+		}
+		if r := groups[key].result(c.GroupBy); active[r.Key()] {
+			results = append(results, r)
+		}
+	}
+
+	report.Results = results
+	return report, nil
+}
+
+// correlated returns true if the result can be collapsed into an incident.
+func correlated(r config.FreshnessResult) bool {
+	return len(r.Violations) > 0 && !r.Silenced()
+}
+
+func correlationKey(r config.FreshnessResult, groupBy string) string {
+	switch groupBy {
+	case "dataset":
+		return r.Project + "." + r.Dataset
+	case "owner":
+		return r.Owner
+	}
+	return ""
+}
+
+// result returns a fresh result which represents the group.
+// It has no table, and only the project and dataset of a group by dataset.
+func (g *correlationGroup) result(groupBy string) config.FreshnessResult {
+	first := g.members[0]
+	r := config.FreshnessResult{
+		Status: config.StatusFresh,
+		Group:  groupBy,
+	}
+	if groupBy == "dataset" {
+		r.Project, r.Dataset = first.Project, first.Dataset
+	}
+
+	r.Owner, r.Runbook = first.Owner, first.Runbook
+	channels := make(map[string]bool)
+	for _, m := range g.members {
+		if m.Owner != r.Owner {
+			r.Owner = ""
+		}
+		if m.Runbook != r.Runbook {
+			r.Runbook = ""
+		}
+		for _, ch := range m.Channels {
+			if !channels[ch] {
+				channels[ch] = true
+				r.Channels = append(r.Channels, ch)
+			}
+		}
+		if m.Severity == config.SeverityCritical || r.Severity == "" {
+			r.Severity = m.Severity
+		}
+	}
+	r.Table = r.FullTableID()
+	return r
+}
+
+// incident returns a result which collapses old tables of the group.
+// Each violation lists the tables which violate the rule.
+func (g *correlationGroup) incident(groupBy string) config.FreshnessResult {
+	r := g.result(groupBy)
+	r.Status = config.StatusStale
+	r.Members = g.old
+	r.Reason = []string{fmt.Sprintf("%d of %d tables in %s %s are not fresh", len(g.old), len(g.members), groupBy, g.key)}
+
+	for _, rule := range config.Rules {
+		var tables []string
+		var severity config.Severity
+		for _, m := range g.old {
+			for _, v := range m.Violations {
+				if v.Rule != rule {
+					continue
+				}
+				if groupBy == "owner" {
+					tables = append(tables, m.FullTableID())
+				} else {
+					tables = append(tables, m.TableID)
+				}
+				if severity == "" || v.Severity == config.SeverityCritical {
+					severity = v.Severity
+				}
+			}
+		}
+		if len(tables) == 0 {
+			continue
+		}
+
+		reason := fmt.Sprintf("%s is violated by %d tables: %s", rule, len(tables), strings.Join(tables, ", "))
+		r.Violations = append(r.Violations, config.Violation{Rule: rule, Reason: reason, Severity: severity})
+		r.Reason = append(r.Reason, reason)
+	}
+	return r
+}
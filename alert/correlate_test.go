@@ -0,0 +1,181 @@
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func correlationResult(dataset, table string, status config.Status, rule string, severity config.Severity) config.FreshnessResult {
+	r := config.FreshnessResult{
+		Project:     "pj",
+		Dataset:     dataset,
+		ConfigTable: table,
+		TableID:     table,
+		Status:      status,
+		Severity:    config.SeverityCritical,
+		Ownership:   config.Ownership{Owner: "data-platform", Channels: []string{"chat-" + dataset}},
+	}
+	if rule != "" {
+		r.Violations = []config.Violation{{Rule: rule, Reason: table + " is old", Severity: severity}}
+	}
+	return r
+}
+
+func TestCorrelate(t *testing.T) {
+	report := config.Report{Results: []config.FreshnessResult{
+		correlationResult("ds1", "a", config.StatusFresh, "", ""),
+		correlationResult("ds1", "b", config.StatusStale, config.RuleDurationThreshold, config.SeverityCritical),
+		correlationResult("ds1", "c", config.StatusStale, config.RuleWarningDurationThreshold, config.SeverityWarning),
+		correlationResult("ds1", "d", config.StatusMissing, config.RuleExists, config.SeverityCritical),
+		correlationResult("ds2", "e", config.StatusFresh, "", ""),
+		correlationResult("ds2", "f", config.StatusStale, config.RuleDurationThreshold, config.SeverityCritical),
+		correlationResult("ds2", "g", config.StatusFresh, "", ""),
+	}}
+
+	actual, err := Correlate(report, Correlation{GroupBy: "dataset"}, map[string]bool{"dataset:pj.ds2": true})
+	assert.NoError(t, err)
+
+	var ids []string
+	for _, r := range actual.Results {
+		ids = append(ids, r.Key())
+	}
+	assert.Equal(t, []string{"pj.ds1.a", "dataset:pj.ds1", "pj.ds2.e", "pj.ds2.f", "pj.ds2.g", "dataset:pj.ds2"}, ids)
+
+	incident := actual.Results[1]
+	assert.Equal(t, config.StatusStale, incident.Status, "an incident is stale even if its members are missing")
+	assert.Equal(t, "dataset", incident.Group)
+	assert.Equal(t, "pj", incident.Project)
+	assert.Equal(t, "ds1", incident.Dataset)
+	assert.Equal(t, "", incident.TableID)
+	assert.Equal(t, "", incident.ConsoleURL())
+	assert.Equal(t, 3, len(incident.Members))
+	assert.Equal(t, config.SeverityCritical, incident.HighestSeverity())
+	assert.Equal(t, []string{
+		"3 of 4 tables in dataset pj.ds1 are not fresh",
+		"exists is violated by 1 tables: d",
+		"duration_threshold is violated by 1 tables: b",
+		"warning_duration_threshold is violated by 1 tables: c",
+	}, incident.Reason)
+	assert.Equal(t, "data-platform", incident.Owner)
+	assert.Equal(t, []string{"chat-ds1"}, incident.Channels)
+
+	group := actual.Results[5]
+	assert.Equal(t, config.StatusFresh, group.Status, "the incident of an active group is resolved")
+	assert.Equal(t, 0, len(group.Violations))
+
+	counts := actual.Counts()
+	assert.Equal(t, 3, counts[config.StatusFresh])
+	assert.Equal(t, 3, counts[config.StatusStale])
+	assert.Equal(t, 1, counts[config.StatusMissing])
+
+	actual, err = Correlate(report, Correlation{GroupBy: "dataset"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(actual.Results), "groups without an active incident have no result")
+}
+
+func TestCorrelate_ByOwner(t *testing.T) {
+	report := config.Report{Results: []config.FreshnessResult{
+		correlationResult("ds1", "a", config.StatusStale, config.RuleDurationThreshold, config.SeverityCritical),
+		correlationResult("ds2", "b", config.StatusStale, config.RuleDurationThreshold, config.SeverityCritical),
+		correlationResult("ds3", "c", config.StatusFresh, "", ""),
+	}}
+	report.Results[1].SilencedBy = "silence"
+
+	active := map[string]bool{"owner:data-platform": true}
+	actual, err := Correlate(report, Correlation{GroupBy: "owner", Ratio: 0.6, MinTables: 1}, active)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(actual.Results), "silenced tables are not collapsed, and 1 of 3 tables is not enough")
+	assert.Equal(t, "owner:data-platform", actual.Results[3].FullTableID())
+	assert.Equal(t, "", actual.Results[3].Project)
+	assert.Equal(t, config.StatusFresh, actual.Results[3].Status)
+	assert.Equal(t, []string{"chat-ds1", "chat-ds2", "chat-ds3"}, actual.Results[3].Channels)
+
+	actual, err = Correlate(report, Correlation{GroupBy: "owner", Ratio: 0.3, MinTables: 1}, active)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(actual.Results))
+	assert.Equal(t, []string{"pj.ds1.a"}, []string{actual.Results[0].Members[0].FullTableID()})
+	assert.Equal(t, "duration_threshold is violated by 1 tables: pj.ds1.a", actual.Results[0].Reason[1])
+
+	_, err = Correlate(report, Correlation{GroupBy: "project"}, nil)
+	assert.Error(t, err)
+}
+
+func TestStore_UpdateIncident(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	c := Correlation{GroupBy: "dataset"}
+	base := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	run := func(i int, results ...config.FreshnessResult) *Transition {
+		active, err := s.ActiveTables("dataset:")
+		assert.NoError(t, err)
+		report, err := Correlate(config.Report{CheckedAt: base.Add(time.Duration(i) * time.Hour), Results: results}, c, active)
+		assert.NoError(t, err)
+		tr, err := s.Update(report, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, tr.Commit(true))
+		return tr
+	}
+	fresh := func(table string) config.FreshnessResult {
+		return correlationResult("ds", table, config.StatusFresh, "", "")
+	}
+	stale := func(table string) config.FreshnessResult {
+		return correlationResult("ds", table, config.StatusStale, config.RuleDurationThreshold, config.SeverityCritical)
+	}
+
+	// a is stale alone, and its alert fires
+	tr := run(0, stale("a"), fresh("b"), fresh("c"))
+	assert.Equal(t, []string{"pj.ds.a"}, keys(tr.Changes.Results))
+
+	// a and b are collapsed into an incident, and only the incident fires
+	tr = run(1, stale("a"), stale("b"), fresh("c"))
+	assert.Equal(t, []string{"dataset:pj.ds"}, keys(tr.Changes.Results))
+
+	// the incident is notified once
+	tr = run(2, stale("a"), stale("b"), fresh("c"))
+	assert.Equal(t, 0, len(tr.Changes.Results))
+
+	// the incident and its members are resolved when it clears
+	tr = run(3, fresh("a"), fresh("b"), fresh("c"))
+	assert.Equal(t, 0, len(tr.Changes.Results))
+	assert.Equal(t, []string{"pj.ds.a", "dataset:pj.ds"}, keys(tr.Changes.Recovered), "b never fired by itself")
+
+	// the resolved incident has no result anymore
+	tr = run(4, fresh("a"), fresh("b"), fresh("c"))
+	assert.Equal(t, 3, len(tr.Report.Results))
+	assert.Equal(t, 0, len(tr.Changes.Recovered))
+
+	// a fires again by itself, and then a and b are collapsed into an incident
+	tr = run(5, stale("a"), fresh("b"), fresh("c"))
+	assert.Equal(t, []string{"pj.ds.a"}, keys(tr.Changes.Results))
+	tr = run(6, stale("a"), stale("b"), fresh("c"))
+	assert.Equal(t, []string{"dataset:pj.ds"}, keys(tr.Changes.Results))
+
+	// a is still stale when the incident clears, so it is notified again
+	tr = run(7, stale("a"), fresh("b"), fresh("c"))
+	assert.Equal(t, []string{"pj.ds.a"}, keys(tr.Changes.Results))
+	assert.Equal(t, []string{"dataset:pj.ds"}, keys(tr.Changes.Recovered))
+
+	// a keeps firing without being notified again
+	tr = run(8, stale("a"), fresh("b"), fresh("c"))
+	assert.Equal(t, 0, len(tr.Changes.Results))
+	assert.Equal(t, 0, len(tr.Changes.Recovered))
+
+	// b is stale by itself after the incident, and fires although it was counted during the incident
+	tr = run(9, fresh("a"), stale("b"), fresh("c"), fresh("d"))
+	assert.Equal(t, []string{"pj.ds.b"}, keys(tr.Changes.Results))
+	assert.Equal(t, []string{"pj.ds.a"}, keys(tr.Changes.Recovered))
+}
+
+func keys(results []config.FreshnessResult) []string {
+	ks := make([]string, 0, len(results))
+	for _, r := range results {
+		ks = append(ks, r.Key())
+	}
+	return ks
+}
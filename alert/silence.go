@@ -0,0 +1,229 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+// Matcher matches tables by regular expressions, which match the whole IDs.
+// Empty expression matches all IDs.
+type Matcher struct {
+	Project string `json:"project,omitempty"`
+	Dataset string `json:"dataset,omitempty"`
+	Table   string `json:"table,omitempty"` // matches the table ID or Table of the table config
+}
+
+// Matches returns true if the result is on the tables matched by m.
+func (m Matcher) Matches(r config.FreshnessResult) (bool, error) {
+	for _, p := range []struct {
+		expr string
+		ids  []string
+	}{
+		{m.Project, []string{r.Project}},
+		{m.Dataset, []string{r.Dataset}},
+		{m.Table, []string{r.TableID, r.ConfigTable}},
+	} {
+		if p.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + p.expr + ")$")
+		if err != nil {
+			return false, xerrors.Errorf("invalid regular expression %s: %w", p.expr, err)
+		}
+		matched := false
+		for _, id := range p.ids {
+			matched = matched || re.MatchString(id)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Silence suppresses alerts of matched tables during a time range.
+type Silence struct {
+	Matcher
+	ID        string    `json:"id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Author    string    `json:"author"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Active returns true if the silence is in effect at t.
+func (s Silence) Active(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// MaintenanceWindow is a recurring silence declared on the settings file.
+type MaintenanceWindow struct {
+	Matcher  `mapstructure:",squash"`
+	Name     string
+	Weekdays []string      // e.g. ["Sat", "Sun"], every day if empty
+	Start    string        // start time of day in "15:04" format on local time zone
+	Duration time.Duration // length of the window
+}
+
+// Active returns true if the window is in effect at t.
+func (w MaintenanceWindow) Active(t time.Time) (bool, error) {
+	clock, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, xerrors.Errorf("invalid start of maintenance window %s: %w", w.Name, err)
+	}
+
+	t = t.In(time.Local)
+	// check windows which started today or on previous days, because a window may span days
+	for days := 0; time.Duration(days)*24*time.Hour < w.Duration+24*time.Hour; days++ {
+		d := t.AddDate(0, 0, -days)
+		start := time.Date(d.Year(), d.Month(), d.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if w.onWeekday(start.Weekday()) && !t.Before(start) && t.Before(start.Add(w.Duration)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (w MaintenanceWindow) onWeekday(wd time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range w.Weekdays {
+		if strings.EqualFold(d, wd.String()[:3]) || strings.EqualFold(d, wd.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplySilences marks results silenced by active silences and maintenance windows at the time of the report.
+func ApplySilences(report config.Report, silences []Silence, windows []MaintenanceWindow) (config.Report, error) {
+	results := make([]config.FreshnessResult, 0, len(report.Results))
+	for _, r := range report.Results {
+		for _, s := range silences {
+			if !s.Active(report.CheckedAt) {
+				continue
+			}
+			matched, err := s.Matches(r)
+			if err != nil {
+				return config.Report{}, err
+			}
+			if matched {
+				r.SilencedBy = "silence " + s.ID
+				break
+			}
+		}
+
+		for _, w := range windows {
+			if r.Silenced() {
+				break
+			}
+			active, err := w.Active(report.CheckedAt)
+			if err != nil {
+				return config.Report{}, err
+			}
+			if !active {
+				continue
+			}
+			matched, err := w.Matches(r)
+			if err != nil {
+				return config.Report{}, err
+			}
+			if matched {
+				r.SilencedBy = "maintenance window " + w.Name
+			}
+		}
+		results = append(results, r)
+	}
+
+	report.Results = results
+	return report, nil
+}
+
+var silenceBucket = []byte("silences")
+
+// AddSilence stores the silence with a new ID, and returns the ID.
+func (s *Store) AddSilence(silence Silence) (string, error) {
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return "", xerrors.New("silence should end after it starts")
+	}
+	for _, expr := range []string{silence.Project, silence.Dataset, silence.Table} {
+		if _, err := regexp.Compile(expr); err != nil {
+			return "", xerrors.Errorf("invalid regular expression %s: %w", expr, err)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", xerrors.Errorf("failed to generate silence ID: %w", err)
+	}
+	silence.ID = hex.EncodeToString(id)
+
+	return silence.ID, s.putSilence(silence)
+}
+
+// Silences returns all silences ordered by their start time.
+func (s *Store) Silences() (silences []Silence, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(silenceBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var silence Silence
+			if err := json.Unmarshal(v, &silence); err != nil {
+				return xerrors.Errorf("failed to decode silence: %w", err)
+			}
+			silences = append(silences, silence)
+			return nil
+		})
+	})
+	sort.Slice(silences, func(i, j int) bool { return silences[i].StartsAt.Before(silences[j].StartsAt) })
+	return silences, err
+}
+
+// ExpireSilence ends the silence at current time.
+func (s *Store) ExpireSilence(id string, current time.Time) error {
+	silences, err := s.Silences()
+	if err != nil {
+		return err
+	}
+	for _, silence := range silences {
+		if silence.ID != id {
+			continue
+		}
+		if !current.Before(silence.EndsAt) {
+			return xerrors.Errorf("silence %s is already expired", id)
+		}
+		silence.EndsAt = current
+		if silence.StartsAt.After(current) {
+			silence.StartsAt = current
+		}
+		return s.putSilence(silence)
+	}
+	return xerrors.Errorf("silence %s is not found", id)
+}
+
+func (s *Store) putSilence(silence Silence) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(silenceBucket)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(silence)
+		if err != nil {
+			return xerrors.Errorf("failed to encode silence: %w", err)
+		}
+		return b.Put([]byte(silence.ID), v)
+	})
+}
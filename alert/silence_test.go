@@ -0,0 +1,100 @@
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher_Matches(t *testing.T) {
+	r := config.FreshnessResult{Project: "pj", Dataset: "ds1", ConfigTable: "sharded_on_", TableID: "sharded_on_20200101"}
+	tests := []struct {
+		m       Matcher
+		wantRes bool
+	}{
+		{m: Matcher{}, wantRes: true},
+		{m: Matcher{Project: "pj", Dataset: "ds[0-9]"}, wantRes: true},
+		{m: Matcher{Dataset: "ds"}, wantRes: false},
+		{m: Matcher{Table: "sharded_on_"}, wantRes: true},
+		{m: Matcher{Table: "sharded_on_2020.*"}, wantRes: true},
+		{m: Matcher{Project: "other", Table: "sharded_on_"}, wantRes: false},
+	}
+	for _, tt := range tests {
+		actual, err := tt.m.Matches(r)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.wantRes, actual, tt.m)
+	}
+}
+
+func TestMaintenanceWindow_Active(t *testing.T) {
+	// 2020-01-04 is Saturday
+	w := MaintenanceWindow{Weekdays: []string{"Sat"}, Start: "23:00", Duration: 3 * time.Hour}
+	tests := []struct {
+		t       time.Time
+		wantRes bool
+	}{
+		{t: time.Date(2020, 1, 4, 22, 59, 0, 0, time.Local), wantRes: false},
+		{t: time.Date(2020, 1, 4, 23, 0, 0, 0, time.Local), wantRes: true},
+		{t: time.Date(2020, 1, 5, 1, 59, 0, 0, time.Local), wantRes: true},
+		{t: time.Date(2020, 1, 5, 2, 0, 0, 0, time.Local), wantRes: false},
+		{t: time.Date(2020, 1, 5, 23, 30, 0, 0, time.Local), wantRes: false},
+	}
+	for _, tt := range tests {
+		actual, err := w.Active(tt.t)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.wantRes, actual, tt.t)
+	}
+}
+
+func TestApplySilences(t *testing.T) {
+	current := time.Date(2020, 1, 4, 10, 0, 0, 0, time.Local)
+	report := config.Report{
+		CheckedAt: current,
+		Results: []config.FreshnessResult{
+			{Project: "pj", Dataset: "ds1", TableID: "a"},
+			{Project: "pj", Dataset: "ds2", TableID: "b"},
+			{Project: "pj", Dataset: "ds3", TableID: "c"},
+			{Project: "pj", Dataset: "ds4", TableID: "d"},
+		},
+	}
+	silences := []Silence{
+		{ID: "s1", Matcher: Matcher{Dataset: "ds1"}, StartsAt: current.Add(-time.Hour), EndsAt: current.Add(time.Hour)},
+		{ID: "s2", Matcher: Matcher{Dataset: "ds2"}, StartsAt: current.Add(-2 * time.Hour), EndsAt: current.Add(-time.Hour)},
+	}
+	windows := []MaintenanceWindow{
+		{Name: "w1", Matcher: Matcher{Dataset: "ds3"}, Start: "09:00", Duration: 2 * time.Hour},
+	}
+
+	actual, err := ApplySilences(report, silences, windows)
+	assert.NoError(t, err)
+	assert.Equal(t, "silence s1", actual.Results[0].SilencedBy)
+	assert.Equal(t, "", actual.Results[1].SilencedBy, "expired silence")
+	assert.Equal(t, "maintenance window w1", actual.Results[2].SilencedBy)
+	assert.Equal(t, "", actual.Results[3].SilencedBy)
+}
+
+func TestStore_Silences(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	current := time.Date(2020, 1, 4, 10, 0, 0, 0, time.UTC)
+	_, err = s.AddSilence(Silence{StartsAt: current, EndsAt: current})
+	assert.Error(t, err)
+
+	id, err := s.AddSilence(Silence{Matcher: Matcher{Dataset: "ds"}, StartsAt: current, EndsAt: current.Add(time.Hour), Comment: "outage"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.ExpireSilence(id, current.Add(time.Minute)))
+	assert.Error(t, s.ExpireSilence(id, current.Add(2*time.Minute)), "already expired")
+	assert.Error(t, s.ExpireSilence("unknown", current))
+
+	silences, err := s.Silences()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(silences))
+	assert.Equal(t, current.Add(time.Minute), silences[0].EndsAt)
+	assert.Equal(t, "outage", silences[0].Comment)
+}
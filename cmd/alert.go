@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hirosassa/tblmonit/alert"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	rootCmd.AddCommand(newAlert())
+}
+
+func newAlert() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alert",
+		Short: "Show and acknowledge alerts",
+		Long: `Show and acknowledge alerts tracked by freshness command.
+The alert store should be configured on the settings file.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newAlertListCmd(),
+		newAlertAckCmd(),
+	)
+	return cmd
+}
+
+func newAlertListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [project.dataset.table prefix]",
+		Short: "List alerts and their states",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prefix := ""
+			if len(args) > 0 {
+				prefix = args[0]
+			}
+			return runAlertListCmd(prefix)
+		},
+	}
+
+	return cmd
+}
+
+func runAlertListCmd(prefix string) error {
+	store, err := openAlertStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	alerts, err := store.List(prefix)
+	if err != nil {
+		return xerrors.Errorf("failed to list alerts: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tRULE\tSTATE\tSINCE\tREASON")
+	for _, a := range alerts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.Table, a.Rule, a.State, a.Since.In(time.Local).Format(time.RFC3339), a.Reason)
+	}
+	return w.Flush()
+}
+
+func newAlertAckCmd() *cobra.Command {
+	var rule string
+	cmd := &cobra.Command{
+		Use:   "ack <project.dataset.table>",
+		Short: "Acknowledge firing alerts of a table",
+		Long: `Acknowledge firing alerts of a table.
+Acknowledged alerts are not notified again until the table becomes fresh.
+For sharded tables, specify the prefix of the tables.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAlertAckCmd(args[0], rule)
+		},
+	}
+
+	cmd.Flags().StringVarP(&rule, "rule", "r", "", "rule to acknowledge, all rules if empty")
+
+	return cmd
+}
+
+func runAlertAckCmd(table, rule string) error {
+	store, err := openAlertStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	acked, err := store.Acknowledge(table, rule, time.Now())
+	if err != nil {
+		return xerrors.Errorf("failed to acknowledge alerts: %w", err)
+	}
+	if len(acked) == 0 {
+		return xerrors.Errorf("no firing alerts of %s", table)
+	}
+	for _, a := range acked {
+		fmt.Printf("acknowledged: %s %s\n", a.Table, a.Rule)
+	}
+	return nil
+}
+
+func openAlertStore() (*alert.Store, error) {
+	if cfg.Alerting.Path == "" {
+		return nil, xerrors.New("alert store is not configured on the settings file")
+	}
+	return alert.Open(cfg.Alerting.Path)
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/hirosassa/tblmonit/flexconfig"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
 )
@@ -53,7 +56,11 @@ func runConfigExpandCmd(cmd *cobra.Command, args []string) error {
 		return xerrors.Errorf("failed to load target config file: %w", err)
 	}
 
-	config, err := targetConfig.Expand()
+	ctx, tracer := startTracing(context.Background())
+	config, err := targetConfig.ExpandContext(ctx)
+	if err := exportTelemetry(tracer, nil, time.Now()); err != nil {
+		log.Error().Err(err).Msg("failed to export telemetry")
+	}
 	if err != nil {
 		return xerrors.Errorf("failed to expand input config file: %w", err)
 	}
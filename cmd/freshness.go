@@ -1,12 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/hirosassa/tblmonit/alert"
 	"github.com/hirosassa/tblmonit/config"
+	"github.com/hirosassa/tblmonit/history"
+	"github.com/hirosassa/tblmonit/metrics"
+	"github.com/hirosassa/tblmonit/notify"
+	"github.com/hirosassa/tblmonit/output"
+	"github.com/hirosassa/tblmonit/telemetry"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/xerrors"
 
@@ -17,8 +27,23 @@ func init() {
 	rootCmd.AddCommand(newFreshness())
 }
 
+type freshnessOptions struct {
+	showDetail bool
+	exitCode   bool
+	owners     []string
+	groupBy    string
+	format     string
+	out        string
+	sortBy     string
+
+	prometheusTextfile  string
+	pushgateway         string
+	pushgatewayJob      string
+	pushgatewayInstance string
+}
+
 func newFreshness() *cobra.Command {
-	var showDetail bool
+	var opts freshnessOptions
 	cmd := &cobra.Command{
 		Use:   "freshness",
 		Short: "Check freshness for each table",
@@ -27,43 +52,521 @@ The target tables and time thresholds should be listed on config file.
 `,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runFreshnessCmd(args, showDetail)
+			err := runFreshnessCmd(args, opts)
+			var e *exitError
+			if err != nil && opts.format == "nagios" && !xerrors.As(err, &e) {
+				// plugins should report their own failures as UNKNOWN
+				fmt.Printf("FRESHNESS %s - %v\n", output.NagiosUnknown, err)
+				err = &exitError{code: int(output.NagiosUnknown)}
+			}
+			if xerrors.As(err, &e) {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+			}
+			return err
 		},
 	}
 
-	cmd.Flags().BoolVarP(&showDetail, "detail", "d", false, "show details of a specific reason of old tables")
+	cmd.Flags().BoolVarP(&opts.showDetail, "detail", "d", false, "show details of a specific reason of old tables")
+	cmd.Flags().BoolVar(&opts.exitCode, "exit-code", false, "exit with 1 if old tables are warning, 2 if critical")
+	cmd.Flags().StringSliceVar(&opts.owners, "owner", nil, "show only tables of the owners")
+	cmd.Flags().StringVar(&opts.groupBy, "group-by", "", "group tables by the key, only \"owner\" is supported")
+	cmd.Flags().StringVar(&opts.format, "format", "text", "output format: text, nagios, junit, github, html or table")
+	cmd.Flags().StringVar(&opts.out, "out", "", "write the output to the file instead of stdout")
+	cmd.Flags().StringVar(&opts.sortBy, "sort", "severity", "sort key of tables on table format: severity or lag")
+	cmd.Flags().StringVar(&opts.prometheusTextfile, "prometheus-textfile", "", "write metrics to the file for node_exporter textfile collector")
+	cmd.Flags().StringVar(&opts.pushgateway, "pushgateway", "", "push metrics to Prometheus Pushgateway on the URL")
+	cmd.Flags().StringVar(&opts.pushgatewayJob, "pushgateway-job", "tblmonit", "job label of metrics pushed to Pushgateway")
+	cmd.Flags().StringVar(&opts.pushgatewayInstance, "pushgateway-instance", "", "instance label of metrics pushed to Pushgateway (default is hostname)")
 
 	return cmd
 }
 
-func runFreshnessCmd(args []string, showDetail bool) error {
-	var targetConfig config.Config
-	_, err := toml.DecodeFile(args[0], &targetConfig)
+func runFreshnessCmd(args []string, opts freshnessOptions) error {
+	switch opts.format {
+	case "text", "nagios", "junit", "github", "html", "table":
+	default:
+		return xerrors.Errorf("unknown format: %s", opts.format)
+	}
+	if opts.format == "github" && opts.out != "" {
+		// GitHub Actions reads workflow commands only from stdout
+		return xerrors.New("--out is not supported by github format")
+	}
+
+	if opts.sortBy != "severity" && opts.sortBy != "lag" {
+		return xerrors.Errorf("unknown sort key: %s", opts.sortBy)
+	}
+
+	var store *alert.Store
+	if cfg.Alerting.Path != "" {
+		var err error
+		store, err = alert.Open(cfg.Alerting.Path)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	ctx, tracer := startTracing(context.Background())
+	report, err := checkTables(ctx, args[0], time.Now())
+	if err != nil {
+		if err := exportTelemetry(tracer, nil, time.Now()); err != nil {
+			log.Error().Err(err).Msg("failed to export telemetry")
+		}
+		return err
+	}
+	if store == nil {
+		if err := requireAlertStore(report); err != nil {
+			return err
+		}
+	}
+	report, err = applySilences(store, report)
+	if err != nil {
+		return xerrors.Errorf("failed to apply silences: %w", err)
+	}
+	// notifiers receive filtered results, so they are given the totals explicitly
+	report.Totals = report.Counts()
+
+	exit, err := printReport(report, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := afterCheck(tracer, store, report, opts); err != nil {
+		if opts.format != "nagios" {
+			return err
+		}
+		// the state is already printed, so failures after the check are reported to stderr without changing it
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+
+	if exit != nil {
+		return exit
+	}
+	if opts.exitCode {
+		return severityExitError(report)
+	}
+	return nil
+}
+
+// requireAlertStore returns an error if tables have ConsecutiveStale or ConsecutiveFresh,
+// or results are correlated, which can't be applied without the alert store.
+func requireAlertStore(report config.Report) error {
+	if cfg.Alerting.Correlation.GroupBy != "" {
+		return xerrors.New("alerting.correlation requires alerting.path on the settings file")
+	}
+	for _, r := range report.Results {
+		if r.ConsecutiveStale > 1 || r.ConsecutiveFresh > 1 {
+			return xerrors.Errorf("ConsecutiveStale and ConsecutiveFresh of %s require alerting.path on the settings file", r.Key())
+		}
+	}
+	return nil
+}
+
+// afterCheck saves the report to the history, exports metrics and telemetry, and sends notifications.
+// Failures of the history, metrics and telemetry are logged, so that they never block notifications.
+func afterCheck(tracer *telemetry.Tracer, store *alert.Store, report config.Report, opts freshnessOptions) error {
+	if err := saveHistory(report); err != nil {
+		log.Error().Err(err).Msg("failed to save history")
+	}
+
+	exportMetrics(report, opts)
+	if err := exportTelemetry(tracer, metrics.ReportFamilies(report), report.CheckedAt); err != nil {
+		log.Error().Err(err).Msg("failed to export telemetry")
+	}
+
+	dispatcher := notify.Dispatcher{Notifiers: cfg.Notifiers}
+	if store == nil {
+		if err := dispatcher.Dispatch(context.Background(), report, report); err != nil {
+			return xerrors.Errorf("failed to send notifications: %w", err)
+		}
+		return nil
+	}
+
+	var active map[string]bool
+	if groupBy := cfg.Alerting.Correlation.GroupBy; groupBy != "" {
+		var err error
+		active, err = store.ActiveTables(groupBy + ":")
+		if err != nil {
+			return xerrors.Errorf("failed to list alerts of incidents: %w", err)
+		}
+	}
+	notified, err := alert.Correlate(report, cfg.Alerting.Correlation, active)
+	if err != nil {
+		return xerrors.Errorf("failed to correlate results: %w", err)
+	}
+	transition, err := store.Update(notified, cfg.Alerting.RepeatInterval)
+	if err != nil {
+		return xerrors.Errorf("failed to update alert states: %w", err)
+	}
+	dispatcher.Batches = store
+	// alert states are saved after notifications, so that undelivered alerts are notified again on the next run
+	dispatchErr := dispatcher.Dispatch(context.Background(), transition.Report, transition.Changes)
+	if err := transition.Commit(dispatchErr == nil); err != nil {
+		return xerrors.Errorf("failed to save alert states: %w", err)
+	}
+	if dispatchErr != nil {
+		return xerrors.Errorf("failed to send notifications: %w", dispatchErr)
+	}
+	return nil
+}
+
+// printReport prints results of tables owned by the owners in the format to stdout or the output file.
+// It returns exitError if the format determines the exit code by itself.
+func printReport(report config.Report, opts freshnessOptions) (exit *exitError, err error) {
+	report.Results = filterByOwners(report.Results, opts.owners)
+
+	w := io.Writer(os.Stdout)
+	if opts.out != "" {
+		var f *os.File
+		f, err = os.Create(opts.out)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create output file: %w", err)
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = xerrors.Errorf("failed to write output file: %w", cerr)
+			}
+		}()
+		w = f
+	}
+
+	switch opts.format {
+	case "text":
+		return nil, printOldTables(w, report.Failures(), opts)
+	case "nagios":
+		state, err := output.WriteNagios(w, report)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to write nagios output: %w", err)
+		}
+		if state == output.NagiosOK {
+			return nil, nil
+		}
+		return &exitError{code: int(state)}, nil
+	case "junit":
+		if err := output.WriteJUnit(w, report); err != nil {
+			return nil, xerrors.Errorf("failed to write junit report: %w", err)
+		}
+		return nil, nil
+	case "github":
+		if err := output.WriteGitHubAnnotations(w, report); err != nil {
+			return nil, xerrors.Errorf("failed to write annotations: %w", err)
+		}
+		return nil, writeGitHubSummary(report)
+	case "html":
+		timelines, err := loadTimelines(report)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load history: %w", err)
+		}
+		if err := output.WriteHTML(w, report, timelines); err != nil {
+			return nil, xerrors.Errorf("failed to write html report: %w", err)
+		}
+		return nil, nil
+	case "table":
+		tableOpts := output.TableOptions{SortBy: opts.sortBy, Color: opts.out == "" && colorTerminal()}
+		if err := output.WriteTable(w, report, tableOpts); err != nil {
+			return nil, xerrors.Errorf("failed to write table: %w", err)
+		}
+		return nil, nil
+	}
+	return nil, xerrors.Errorf("unknown format: %s", opts.format)
+}
+
+// colorTerminal returns true if stdout is a terminal and colors are not disabled by NO_COLOR.
+func colorTerminal() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// sparklineRecords is the number of records shown on sparklines of the html report.
+const sparklineRecords = 30
+
+// loadTimelines returns recent records of tables on the report followed by the current results,
+// or nil if the history store is not configured.
+func loadTimelines(report config.Report) (map[string][]history.Record, error) {
+	if cfg.History.Path == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(cfg.History.Path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	store, err := history.OpenReadOnly(cfg.History.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	timelines := make(map[string][]history.Record)
+	for _, r := range report.Results {
+		records, err := store.Timeline(r.Key(), sparklineRecords-1)
+		if err != nil {
+			return nil, err
+		}
+		timelines[r.Key()] = append(records, history.Record{
+			CheckedAt:        report.CheckedAt,
+			TableID:          r.TableID,
+			Status:           r.Status,
+			LastModifiedTime: r.LastModifiedTime,
+			NumRows:          r.NumRows,
+			Reason:           r.Reason,
+		})
+	}
+	return timelines, nil
+}
+
+// writeGitHubSummary appends results to the job summary if it runs on GitHub Actions.
+func writeGitHubSummary(report config.Report) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		log.Info().Msg("GITHUB_STEP_SUMMARY is not set, skip writing job summary")
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return xerrors.Errorf("failed to load target config file: %w", err)
+		return xerrors.Errorf("failed to open job summary: %w", err)
 	}
+	if err := output.WriteGitHubSummary(f, report); err != nil {
+		f.Close()
+		return xerrors.Errorf("failed to write job summary: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("failed to write job summary: %w", err)
+	}
+	return nil
+}
 
-	current := time.Now()
-	oldTables, err := config.CheckFreshness(targetConfig, current)
+// checkTables checks freshness of tables listed on the target config file, and renders reasons by the templates.
+func checkTables(ctx context.Context, path string, current time.Time) (config.Report, error) {
+	var targetConfig config.Config
+	if _, err := toml.DecodeFile(path, &targetConfig); err != nil {
+		return config.Report{}, xerrors.Errorf("failed to load target config file: %w", err)
+	}
+
+	results, err := config.CheckTablesContext(ctx, targetConfig, current)
 	if err != nil {
-		return xerrors.Errorf("failed to check freshness: %w", err)
+		return config.Report{}, xerrors.Errorf("failed to check freshness: %w", err)
 	}
+	report, err := cfg.Templates.Reasons.Apply(config.Report{CheckedAt: current, Results: results})
+	if err != nil {
+		return config.Report{}, xerrors.Errorf("failed to apply reason templates: %w", err)
+	}
+	return report, nil
+}
+
+// severityExitError returns exitError by the highest severity of old tables which are not silenced.
+func severityExitError(report config.Report) error {
+	code := 0
+	for _, r := range report.Failures() {
+		if r.Silenced() {
+			continue
+		}
+		switch r.HighestSeverity() {
+		case config.SeverityCritical:
+			code = 2
+		case config.SeverityWarning:
+			if code == 0 {
+				code = 1
+			}
+		}
+	}
+	if code == 0 {
+		return nil
+	}
+	return &exitError{code: code}
+}
+
+// defaultLineTemplate is the template of a line for each old table.
+const defaultLineTemplate = `{{.Table}}{{if .Detail}} [{{.HighestSeverity}}] ({{join "," .Reason}}){{end}}{{if .Silenced}} [silenced by {{.SilencedBy}}]{{end}}`
 
+// lineData is data passed to the line template.
+type lineData struct {
+	config.FreshnessResult
+	Detail bool // true if --detail is specified
+}
+
+func printOldTables(w io.Writer, oldTables []config.FreshnessResult, opts freshnessOptions) error {
 	if len(oldTables) == 0 {
 		log.Info().Msg("All tables are fresh enough!")
 		return nil
 	}
 
+	text := cfg.Templates.Line
+	if text == "" {
+		text = defaultLineTemplate
+	}
+	tmpl, err := template.New("line").Funcs(config.TemplateFuncs).Parse(text)
+	if err != nil {
+		return xerrors.Errorf("failed to parse line template: %w", err)
+	}
+
 	var result strings.Builder
+	switch opts.groupBy {
+	case "":
+		err = writeOldTables(&result, tmpl, oldTables, opts.showDetail)
+	case "owner":
+		for _, g := range (config.Report{Results: oldTables, GroupBy: "owner"}).Groups() {
+			owner := g.Key
+			if owner == "" {
+				owner = "(none)"
+			}
+			result.WriteString(fmt.Sprintf("# owner: %s\n", owner))
+			if err = writeOldTables(&result, tmpl, g.Results, opts.showDetail); err != nil {
+				break
+			}
+		}
+	default:
+		return xerrors.Errorf("unknown group-by key: %s", opts.groupBy)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to render old tables: %w", err)
+	}
+	_, err = io.WriteString(w, result.String())
+	return err
+}
+
+func writeOldTables(result *strings.Builder, tmpl *template.Template, oldTables []config.FreshnessResult, showDetail bool) error {
 	for _, t := range oldTables {
-		result.WriteString(t.Table)
-		if showDetail {
-			reason := fmt.Sprintf(" (%s)", strings.Join(t.Reason, ","))
-			result.WriteString(reason)
+		if err := tmpl.Execute(result, lineData{FreshnessResult: t, Detail: showDetail}); err != nil {
+			return err
 		}
 		result.WriteString("\n")
 	}
-	fmt.Print(result.String())
+	return nil
+}
+
+// filterByOwners returns results of tables owned by owners, or all results if owners is empty.
+func filterByOwners(results []config.FreshnessResult, owners []string) []config.FreshnessResult {
+	if len(owners) == 0 {
+		return results
+	}
+
+	filtered := make([]config.FreshnessResult, 0, len(results))
+	for _, r := range results {
+		for _, o := range owners {
+			if r.Owner == o {
+				filtered = append(filtered, r)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func saveHistory(report config.Report) error {
+	if cfg.History.Path == "" {
+		return nil
+	}
+
+	store, err := history.Open(cfg.History.Path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Save(report); err != nil {
+		return err
+	}
+	removed, err := store.Prune(cfg.History, report.CheckedAt)
+	if err != nil {
+		return xerrors.Errorf("failed to prune history: %w", err)
+	}
+	log.Info().Msgf("removed %d old records from history", removed)
+	return nil
+}
+
+// exportMetrics writes metrics of the report to the textfile, pushes them to Pushgateway,
+// and sends them to StatsD and Cloud Monitoring if they are specified.
+// Failures are logged instead of returned, so that metric sinks never block alerting or the exit code.
+func exportMetrics(report config.Report, opts freshnessOptions) {
+	families := metrics.ReportFamilies(report)
+	if opts.prometheusTextfile != "" {
+		if err := metrics.WriteTextfile(opts.prometheusTextfile, families); err != nil {
+			log.Error().Err(err).Msg("failed to write metrics to the textfile")
+		}
+	}
+
+	if opts.pushgateway != "" {
+		if err := pushMetrics(families, opts); err != nil {
+			log.Error().Err(err).Msg("failed to push metrics to pushgateway")
+		}
+	}
+
+	if cfg.StatsD.Address != "" {
+		if err := metrics.SendStatsD(cfg.StatsD, report); err != nil {
+			log.Error().Err(err).Msg("failed to send metrics to statsd")
+		}
+	}
+
+	if cfg.CloudMonitoring.Project != "" {
+		ctx := context.Background()
+		cm, err := metrics.NewCloudMonitoring(ctx, cfg.CloudMonitoring.Project)
+		if err == nil {
+			err = cm.Write(ctx, report)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write metrics to cloud monitoring")
+		}
+	}
+}
 
+func pushMetrics(families []metrics.Family, opts freshnessOptions) error {
+	instance := opts.pushgatewayInstance
+	if instance == "" {
+		var err error
+		instance, err = os.Hostname()
+		if err != nil {
+			return xerrors.Errorf("failed to get hostname: %w", err)
+		}
+	}
+	pusher := metrics.Pusher{
+		URL:      opts.pushgateway,
+		Job:      opts.pushgatewayJob,
+		Grouping: []metrics.Label{{Name: "instance", Value: instance}},
+	}
+	return pusher.Push(context.Background(), families)
+}
+
+// startTracing returns a context in which spans are recorded on the tracer if OpenTelemetry is configured.
+func startTracing(ctx context.Context) (context.Context, *telemetry.Tracer) {
+	if !cfg.OpenTelemetry.Enabled() {
+		return ctx, nil
+	}
+	tracer := &telemetry.Tracer{}
+	return telemetry.ContextWithTracer(ctx, tracer), tracer
+}
+
+// exportTelemetry sends spans recorded on the tracer and the metrics observed at t to OTLP receiver
+// if OpenTelemetry is configured.
+func exportTelemetry(tracer *telemetry.Tracer, families []metrics.Family, t time.Time) error {
+	if tracer == nil {
+		return nil
+	}
+	ctx := context.Background()
+	exporter := telemetry.NewExporter(cfg.OpenTelemetry)
+	if err := exporter.ExportSpans(ctx, tracer.Flush()); err != nil {
+		return xerrors.Errorf("failed to export traces: %w", err)
+	}
+	if err := metrics.ExportOTLP(ctx, exporter, families, t); err != nil {
+		return xerrors.Errorf("failed to export metrics: %w", err)
+	}
 	return nil
 }
+
+// applySilences marks results silenced by silences on the alert store and maintenance windows.
+func applySilences(store *alert.Store, report config.Report) (config.Report, error) {
+	var silences []alert.Silence
+	if store != nil {
+		var err error
+		silences, err = store.Silences()
+		if err != nil {
+			return config.Report{}, err
+		}
+	}
+
+	return alert.ApplySilences(report, silences, cfg.Alerting.MaintenanceWindows)
+}
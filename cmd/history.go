@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hirosassa/tblmonit/history"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	rootCmd.AddCommand(newHistory())
+}
+
+func newHistory() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "history <project.dataset.table>",
+		Short: "Show freshness history of a table",
+		Long: `Show timeline of freshness results of a table recorded by freshness command.
+The history store should be configured on the settings file.
+For sharded tables, specify the prefix of the tables.
+For example:
+
+tblmonit history bigquery-project-id-1.dataset2.sharded_table2_on_`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistoryCmd(args, limit)
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "number of the latest records to show, all records if 0")
+
+	return cmd
+}
+
+func runHistoryCmd(args []string, limit int) error {
+	if cfg.History.Path == "" {
+		return xerrors.New("history store is not configured on the settings file")
+	}
+
+	store, err := history.OpenReadOnly(cfg.History.Path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.Timeline(args[0], limit)
+	if err != nil {
+		return xerrors.Errorf("failed to load history: %w", err)
+	}
+	if len(records) == 0 {
+		return xerrors.Errorf("no history of %s", args[0])
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECKED AT\tTABLE\tSTATUS\tLAST MODIFIED\tNUM ROWS\tREASON")
+	for _, r := range records {
+		lastModified := "-"
+		if !r.LastModifiedTime.IsZero() {
+			lastModified = r.LastModifiedTime.In(time.Local).Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			r.CheckedAt.In(time.Local).Format(time.RFC3339), r.TableID, r.Status, lastModified, r.NumRows, strings.Join(r.Reason, ","))
+	}
+	return w.Flush()
+}
@@ -5,10 +5,17 @@ import (
 	"os"
 	"time"
 
+	"github.com/hirosassa/tblmonit/alert"
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/hirosassa/tblmonit/history"
+	"github.com/hirosassa/tblmonit/metrics"
+	"github.com/hirosassa/tblmonit/notify"
+	"github.com/hirosassa/tblmonit/telemetry"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
 )
 
 var cfgFile string
@@ -16,7 +23,20 @@ var cfgFile string
 var cfg tmConfig
 
 type tmConfig struct {
-	TimeZone string
+	TimeZone        string
+	Notifiers       []notify.Config
+	History         history.Config
+	Alerting        alert.Config
+	Templates       templates
+	StatsD          metrics.StatsDConfig
+	OpenTelemetry   telemetry.Config
+	CloudMonitoring metrics.CloudMonitoringConfig
+}
+
+// templates are Go text/templates to customize messages.
+type templates struct {
+	Reasons config.ReasonTemplates // templates of reasons keyed by rules
+	Line    string                 // template of a line for each old table on the output of freshness command
 }
 
 var verbose, debug bool // for verbose and debug output
@@ -28,8 +48,21 @@ var rootCmd = &cobra.Command{
 	Long:  `Monitoring BigQuery table metadata to ensure the data pipeline jobs are correctly worked.`,
 }
 
+// exitError makes the command exit with the code without printing an error.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var e *exitError
+		if xerrors.As(err, &e) {
+			os.Exit(e.code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hirosassa/tblmonit/metrics"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	rootCmd.AddCommand(newServe())
+}
+
+type serveOptions struct {
+	listen   string
+	interval time.Duration
+}
+
+func newServe() *cobra.Command {
+	var opts serveOptions
+	cmd := &cobra.Command{
+		Use:   "serve [target config file]",
+		Short: "Check freshness periodically and expose Prometheus metrics",
+		Long: `Check freshness of tables listed on the config file periodically,
+and expose the results as Prometheus metrics on /metrics.
+The config file is loaded on each check, so changes of it take effect without restart.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServeCmd(args, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.listen, "listen", ":9797", "address to listen on for HTTP requests")
+	cmd.Flags().DurationVar(&opts.interval, "interval", 5*time.Minute, "interval of checks")
+
+	return cmd
+}
+
+func runServeCmd(args []string, opts serveOptions) error {
+	if opts.interval <= 0 {
+		return xerrors.Errorf("interval should be positive: %s", opts.interval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var cm *metrics.CloudMonitoring
+	if cfg.CloudMonitoring.Project != "" {
+		var err error
+		cm, err = metrics.NewCloudMonitoring(ctx, cfg.CloudMonitoring.Project)
+		if err != nil {
+			return err
+		}
+	}
+
+	exporter := &metrics.Exporter{}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	srv := &http.Server{Addr: opts.listen, Handler: mux}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Info().Msgf("listening on %s", opts.listen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errc <- xerrors.Errorf("failed to serve metrics: %w", err)
+		}
+	}()
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		runCheck(args[0], exporter, cm)
+
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCheck checks freshness of tables, and updates the metrics by the results.
+// The metrics are also sent to StatsD, OTLP receiver and Cloud Monitoring if they are configured.
+func runCheck(path string, exporter *metrics.Exporter, cm *metrics.CloudMonitoring) {
+	ctx, tracer := startTracing(context.Background())
+	start := time.Now()
+	report, err := checkTables(ctx, path, start)
+	exporter.Update(report, time.Since(start), err)
+	if err := exportTelemetry(tracer, exporter.Families(), time.Now()); err != nil {
+		log.Error().Err(err).Msg("failed to export telemetry")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check freshness")
+		return
+	}
+	log.Info().Msgf("checked %d tables in %s", len(report.Results), time.Since(start))
+
+	if cfg.StatsD.Address != "" {
+		if err := metrics.SendStatsD(cfg.StatsD, report); err != nil {
+			log.Error().Err(err).Msg("failed to send metrics to statsd")
+		}
+	}
+
+	if cm != nil {
+		if err := cm.Write(ctx, report); err != nil {
+			log.Error().Err(err).Msg("failed to write metrics to cloud monitoring")
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hirosassa/tblmonit/alert"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	rootCmd.AddCommand(newSilence())
+}
+
+func newSilence() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "silence",
+		Short: "Manage silences of alerts",
+		Long: `Manage time-bounded silences of alerts.
+Silenced tables are still checked, but they are marked as silenced instead of alerting.
+The alert store should be configured on the settings file.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newSilenceAddCmd(),
+		newSilenceListCmd(),
+		newSilenceExpireCmd(),
+	)
+	return cmd
+}
+
+func newSilenceAddCmd() *cobra.Command {
+	var silence alert.Silence
+	var startsAt string
+	var duration time.Duration
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a silence",
+		Long: `Add a silence for tables matched by regular expressions.
+At least one of --project, --dataset and --table is required, or --all to silence all tables.
+For example:
+
+tblmonit silence add --dataset 'dataset[12]' --duration 3h --comment "upstream outage"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if silence.Project == "" && silence.Dataset == "" && silence.Table == "" && !all {
+				return xerrors.New("specify at least one of --project, --dataset and --table, or --all to silence all tables")
+			}
+			return runSilenceAddCmd(silence, startsAt, duration)
+		},
+	}
+
+	cmd.Flags().StringVar(&silence.Project, "project", "", "regular expression of project IDs, all projects if empty")
+	cmd.Flags().StringVar(&silence.Dataset, "dataset", "", "regular expression of dataset IDs, all datasets if empty")
+	cmd.Flags().StringVar(&silence.Table, "table", "", "regular expression of table IDs, all tables if empty")
+	cmd.Flags().BoolVar(&all, "all", false, "silence all tables")
+	cmd.Flags().StringVar(&startsAt, "starts-at", "", "start time of the silence in RFC3339 format (default now)")
+	cmd.Flags().DurationVar(&duration, "duration", 2*time.Hour, "duration of the silence")
+	cmd.Flags().StringVar(&silence.Author, "author", os.Getenv("USER"), "author of the silence")
+	cmd.Flags().StringVar(&silence.Comment, "comment", "", "reason of the silence (required)")
+	_ = cmd.MarkFlagRequired("comment")
+
+	return cmd
+}
+
+func runSilenceAddCmd(silence alert.Silence, startsAt string, duration time.Duration) error {
+	current := time.Now()
+	silence.CreatedAt = current
+	silence.StartsAt = current
+	if startsAt != "" {
+		t, err := time.Parse(time.RFC3339, startsAt)
+		if err != nil {
+			return xerrors.Errorf("invalid start time: %w", err)
+		}
+		silence.StartsAt = t
+	}
+	silence.EndsAt = silence.StartsAt.Add(duration)
+
+	store, err := openAlertStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	id, err := store.AddSilence(silence)
+	if err != nil {
+		return xerrors.Errorf("failed to add silence: %w", err)
+	}
+	fmt.Println(id)
+	return nil
+}
+
+func newSilenceListCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List silences",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSilenceListCmd(all)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "show expired silences too")
+
+	return cmd
+}
+
+func runSilenceListCmd(all bool) error {
+	store, err := openAlertStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	silences, err := store.Silences()
+	if err != nil {
+		return xerrors.Errorf("failed to list silences: %w", err)
+	}
+
+	current := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPROJECT\tDATASET\tTABLE\tSTARTS AT\tENDS AT\tAUTHOR\tCOMMENT")
+	for _, s := range silences {
+		if !all && !current.Before(s.EndsAt) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, orAny(s.Project), orAny(s.Dataset), orAny(s.Table),
+			s.StartsAt.In(time.Local).Format(time.RFC3339), s.EndsAt.In(time.Local).Format(time.RFC3339), s.Author, s.Comment)
+	}
+	return w.Flush()
+}
+
+func orAny(expr string) string {
+	if expr == "" {
+		return "*"
+	}
+	return expr
+}
+
+func newSilenceExpireCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "expire <silence ID>...",
+		Short: "Expire silences",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSilenceExpireCmd(args)
+		},
+	}
+
+	return cmd
+}
+
+func runSilenceExpireCmd(ids []string) error {
+	store, err := openAlertStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	current := time.Now()
+	for _, id := range ids {
+		if err := store.ExpireSilence(id, current); err != nil {
+			return xerrors.Errorf("failed to expire silence: %w", err)
+		}
+		fmt.Printf("expired: %s\n", id)
+	}
+	return nil
+}
@@ -3,11 +3,14 @@ package config
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	bq "cloud.google.com/go/bigquery"
+	"github.com/hirosassa/tblmonit/telemetry"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/xerrors"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -16,20 +19,50 @@ type Config struct {
 }
 
 type Project struct {
-	ID      string
+	ID string
+	Ownership
 	Dataset []Dataset
 }
 
 type Dataset struct {
-	ID          string
+	ID string
+	Ownership
 	TableConfig []TableConfig
 }
 
+// Ownership is metadata about the owner of tables.
+// It is inherited from Project to Dataset, and from Dataset to TableConfig unless they override it.
+type Ownership struct {
+	Owner    string   `toml:",omitempty" json:"owner,omitempty"`    // owner team
+	Channels []string `toml:",omitempty" json:"channels,omitempty"` // names of notifiers to route alerts
+	Runbook  string   `toml:",omitempty" json:"runbook,omitempty"`  // URL of the runbook
+}
+
+// inherit returns the ownership whose empty fields are filled by parent.
+func (o Ownership) inherit(parent Ownership) Ownership {
+	if o.Owner == "" {
+		o.Owner = parent.Owner
+	}
+	if len(o.Channels) == 0 {
+		o.Channels = parent.Channels
+	}
+	if o.Runbook == "" {
+		o.Runbook = parent.Runbook
+	}
+	return o
+}
+
 type TableConfig struct {
-	Table             string
-	DateForShards     string
-	TimeThreshold     *TimeThreshold
-	DurationThreshold *DurationThreshold
+	Table                    string
+	DateForShards            string
+	TimeThreshold            *TimeThreshold
+	DurationThreshold        *DurationThreshold
+	WarningTimeThreshold     *TimeThreshold     `toml:",omitempty"`
+	WarningDurationThreshold *DurationThreshold `toml:",omitempty"`
+	Severity                 Severity           `toml:",omitempty"` // severity of TimeThreshold and DurationThreshold
+	ConsecutiveStale         int                `toml:",omitempty"` // number of consecutive stale observations to fire alerts
+	ConsecutiveFresh         int                `toml:",omitempty"` // number of consecutive fresh observations to resolve alerts
+	Ownership
 }
 
 type TimeThreshold struct {
@@ -70,49 +103,115 @@ func getTodaysClockObject(clock, current time.Time) time.Time {
 	return time.Date(y, m, d, hh, mm, ss, 0, time.Local)
 }
 
-type FreshnessResult struct {
-	Table  string
-	Reason []string
-}
-
 // CheckFreshness returns old tables whose last modified time is oldeer than time threshold on the config file.
 func CheckFreshness(config Config, current time.Time, opts ...option.ClientOption) (oldTables []FreshnessResult, err error) {
-	ctx := context.Background()
+	results, err := CheckTables(config, current, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		if r.Status != StatusFresh {
+			oldTables = append(oldTables, r)
+		}
+	}
+	return oldTables, nil
+}
+
+// CheckTables returns freshness results of all tables listed on the config file.
+func CheckTables(config Config, current time.Time, opts ...option.ClientOption) (results []FreshnessResult, err error) {
+	return CheckTablesContext(context.Background(), config, current, opts...)
+}
+
+// CheckTablesContext is CheckTables with a context.
+// Spans of each project, dataset and call of the metadata API are recorded if ctx has a tracer.
+func CheckTablesContext(ctx context.Context, config Config, current time.Time, opts ...option.ClientOption) (results []FreshnessResult, err error) {
+	ctx, span := telemetry.Start(ctx, "CheckTables", telemetry.SpanKindInternal)
+	defer span.Finish()
 
 	for _, pj := range config.Project {
-		client, err := bq.NewClient(ctx, pj.ID, opts...)
+		pjCtx, pjSpan := telemetry.Start(ctx, "project "+pj.ID, telemetry.SpanKindInternal, telemetry.String("bigquery.project", pj.ID))
+		client, err := bq.NewClient(pjCtx, pj.ID, opts...)
 		if err != nil {
+			pjSpan.RecordError(err)
+			pjSpan.Finish()
+			span.RecordError(err)
 			return nil, xerrors.Errorf("failed to create client: %w", err)
 		}
 
 		for _, ds := range pj.Dataset {
+			dsCtx, dsSpan := telemetry.Start(pjCtx, "dataset "+pj.ID+"."+ds.ID, telemetry.SpanKindInternal,
+				telemetry.String("bigquery.project", pj.ID), telemetry.String("bigquery.dataset", ds.ID), telemetry.Int("tblmonit.tables", len(ds.TableConfig)))
+			dsOwnership := ds.Ownership.inherit(pj.Ownership)
 			for _, tc := range ds.TableConfig {
 				tableID := getSuitableTableID(tc)
+				result := FreshnessResult{
+					Table:       fmt.Sprintf("%s.%s.%s", pj.ID, ds.ID, tableID),
+					ConfigTable: tc.Table,
+					Project:     pj.ID,
+					Dataset:     ds.ID,
+					TableID:     tableID,
+					Status:      StatusFresh,
+					Severity:    tc.severity(),
+					Ownership:   tc.Ownership.inherit(dsOwnership),
 
-				md, err := client.Dataset(ds.ID).Table(tableID).Metadata(ctx)
-				if err != nil { // table is not created
+					ConsecutiveStale: tc.ConsecutiveStale,
+					ConsecutiveFresh: tc.ConsecutiveFresh,
+
+					TimeThreshold:            tc.TimeThreshold,
+					DurationThreshold:        tc.DurationThreshold,
+					WarningTimeThreshold:     tc.WarningTimeThreshold,
+					WarningDurationThreshold: tc.WarningDurationThreshold,
+				}
+
+				mdCtx, mdSpan := telemetry.Start(dsCtx, "bigquery.tables.get", telemetry.SpanKindClient,
+					telemetry.String("bigquery.project", pj.ID), telemetry.String("bigquery.dataset", ds.ID), telemetry.String("bigquery.table", tableID))
+				md, err := client.Dataset(ds.ID).Table(tableID).Metadata(mdCtx)
+				if err != nil && !IsNotFound(err) {
+					mdSpan.RecordError(err)
+				}
+				mdSpan.Finish()
+				if err != nil {
 					log.Warn().Msgf("failed to fetch metadata: table: %s.%s", ds.ID, tableID)
 
-					// Before time threshold, table may not exist.
-					if tc.TimeThreshold == nil || current.After(tc.TimeThreshold.Time) {
-						oldTables = append(oldTables, FreshnessResult{
-							Table:  fmt.Sprintf("%s.%s.%s", pj.ID, ds.ID, tableID),
-							Reason: []string{"Table doesn't exist"},
-						})
+					if !IsNotFound(err) {
+						result.Status = StatusError
+						result.Reason = []string{fmt.Sprintf("Failed to fetch metadata: %v", err)}
+						results = append(results, result)
+						continue
+					}
+
+					if v, ok := tc.missingViolation(current); ok {
+						result.Status = StatusMissing
+						result.Reason = []string{v.Reason}
+						result.Violations = []Violation{v}
 					}
+					results = append(results, result)
 					continue
 				}
 
-				if old, reason := tc.isOld(current, md.LastModifiedTime); old {
-					oldTables = append(oldTables, FreshnessResult{
-						Table:  md.FullID,
-						Reason: reason,
-					})
+				result.Table = md.FullID
+				result.LastModifiedTime = md.LastModifiedTime
+				result.NumRows = md.NumRows
+				if vs := tc.violations(current, md.LastModifiedTime); len(vs) > 0 {
+					result.Status = StatusStale
+					result.Violations = vs
+					result.Reason = reasons(vs)
 				}
+				results = append(results, result)
 			}
+			dsSpan.Finish()
 		}
+		client.Close()
+		pjSpan.Finish()
 	}
-	return oldTables, nil
+	return results, nil
+}
+
+// IsNotFound returns true if err is a 404 response of Google APIs, e.g. for a table which does not exist.
+func IsNotFound(err error) bool {
+	var e *googleapi.Error
+	return xerrors.As(err, &e) && e.Code == http.StatusNotFound
 }
 
 func getSuitableTableID(tc TableConfig) string {
@@ -146,37 +245,84 @@ func getSuitableTableID(tc TableConfig) string {
 }
 
 func (t *TableConfig) isOld(current, lastModified time.Time) (isOld bool, reason []string) {
-	isOld, timeReason := t.isOldForTimeThreshold(lastModified)
-	if isOld {
-		reason = append(reason, timeReason)
+	reason = reasons(t.violations(current, lastModified))
+	return len(reason) > 0, reason
+}
+
+// violations returns thresholds which the table violates.
+// Warning thresholds are not reported if the corresponding threshold is violated.
+func (t *TableConfig) violations(current, lastModified time.Time) (vs []Violation) {
+	if isOld, reason := t.isOldForTimeThreshold(lastModified); isOld {
+		vs = append(vs, Violation{Rule: RuleTimeThreshold, Reason: reason, Severity: t.severity()})
+	} else if isOld, reason := isOldForTimeThreshold(t.WarningTimeThreshold, lastModified); isOld {
+		vs = append(vs, Violation{Rule: RuleWarningTimeThreshold, Reason: reason, Severity: SeverityWarning})
 	}
 
-	isOld, durationReason := t.isOldForDurationThreshold(current, lastModified)
-	if isOld {
-		reason = append(reason, durationReason)
+	if isOld, reason := t.isOldForDurationThreshold(current, lastModified); isOld {
+		vs = append(vs, Violation{Rule: RuleDurationThreshold, Reason: reason, Severity: t.severity()})
+	} else if isOld, reason := isOldForDurationThreshold(t.WarningDurationThreshold, current, lastModified); isOld {
+		vs = append(vs, Violation{Rule: RuleWarningDurationThreshold, Reason: reason, Severity: SeverityWarning})
 	}
 
-	return len(reason) > 0, reason
+	return vs
+}
+
+// missingViolation returns the violation of a table which doesn't exist.
+// It is critical (or Severity of the table config) if critical thresholds are configured, and warning if only warning thresholds are.
+// Before the time thresholds, the table may not exist, so it is not a violation, or a warning if only the warning time threshold has passed.
+func (t *TableConfig) missingViolation(current time.Time) (Violation, bool) {
+	severity := t.severity()
+	onlyWarning := t.TimeThreshold == nil && t.DurationThreshold == nil &&
+		(t.WarningTimeThreshold != nil || t.WarningDurationThreshold != nil)
+	if onlyWarning {
+		severity = SeverityWarning
+	}
+
+	switch {
+	case t.TimeThreshold != nil && !current.After(t.TimeThreshold.Time):
+		if t.WarningTimeThreshold == nil || !current.After(t.WarningTimeThreshold.Time) {
+			return Violation{}, false
+		}
+		severity = SeverityWarning
+	case onlyWarning && t.WarningTimeThreshold != nil && !current.After(t.WarningTimeThreshold.Time):
+		return Violation{}, false
+	}
+	return Violation{Rule: RuleExists, Reason: "Table doesn't exist", Severity: severity}, true
+}
+
+func (t *TableConfig) severity() Severity {
+	if t.Severity == "" {
+		return SeverityCritical
+	}
+	return t.Severity
 }
 
 func (t *TableConfig) isOldForTimeThreshold(lastModified time.Time) (isOld bool, reason string) {
-	if t.TimeThreshold == nil {
+	return isOldForTimeThreshold(t.TimeThreshold, lastModified)
+}
+
+func (t *TableConfig) isOldForDurationThreshold(current, lastModified time.Time) (isOld bool, reason string) {
+	return isOldForDurationThreshold(t.DurationThreshold, current, lastModified)
+}
+
+func isOldForTimeThreshold(threshold *TimeThreshold, lastModified time.Time) (isOld bool, reason string) {
+	if threshold == nil {
 		return false, ""
 	}
 
-	if !lastModified.After(t.TimeThreshold.Time) {
+	if !lastModified.After(threshold.Time) {
 		return false, ""
 	}
-	return true, fmt.Sprintf("The table should be created by %s, but last modified time is %s", t.TimeThreshold.Time.Format("15:04"), lastModified.Format("15:04"))
+	return true, fmt.Sprintf("The table should be created by %s, but last modified time is %s", threshold.Time.Format("15:04"), lastModified.Format("15:04"))
 }
 
-func (t *TableConfig) isOldForDurationThreshold(current, lastModified time.Time) (isOld bool, reason string) {
-	if t.DurationThreshold == nil {
+func isOldForDurationThreshold(threshold *DurationThreshold, current, lastModified time.Time) (isOld bool, reason string) {
+	if threshold == nil {
 		return false, ""
 	}
 
-	if current.In(time.Local).Sub(lastModified.In(time.Local)) < t.DurationThreshold.Duration {
+	if current.In(time.Local).Sub(lastModified.In(time.Local)) < threshold.Duration {
 		return false, ""
 	}
-	return true, fmt.Sprintf("The table should be modified in %s, but not modified in %s", t.DurationThreshold.Duration, current.In(time.Local).Sub(lastModified.In(time.Local)))
+	return true, fmt.Sprintf("The table should be modified in %s, but not modified in %s", threshold.Duration, current.In(time.Local).Sub(lastModified.In(time.Local)))
 }
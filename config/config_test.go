@@ -169,3 +169,181 @@ func TestIsOld(t *testing.T) {
 		})
 	}
 }
+
+func TestViolations(t *testing.T) {
+	location, _ := time.LoadLocation("Asia/Tokyo")
+	lastModified := time.Date(2020, 1, 1, 0, 0, 0, 0, location)
+
+	tc := TableConfig{
+		DurationThreshold:        &DurationThreshold{Duration: 24 * time.Hour},
+		WarningDurationThreshold: &DurationThreshold{Duration: 6 * time.Hour},
+	}
+
+	tests := map[string]struct {
+		tc      TableConfig
+		current time.Time
+
+		// output
+		rules    []string
+		severity Severity
+	}{
+		"fresh": {
+			tc:       tc,
+			current:  time.Date(2020, 1, 1, 5, 0, 0, 0, location),
+			severity: "",
+		},
+		"only warning threshold is violated": {
+			tc:       tc,
+			current:  time.Date(2020, 1, 1, 7, 0, 0, 0, location),
+			rules:    []string{RuleWarningDurationThreshold},
+			severity: SeverityWarning,
+		},
+		"warning is not reported if critical threshold is violated": {
+			tc:       tc,
+			current:  time.Date(2020, 1, 2, 1, 0, 0, 0, location),
+			rules:    []string{RuleDurationThreshold},
+			severity: SeverityCritical,
+		},
+		"severity of the table config": {
+			tc: TableConfig{
+				DurationThreshold: &DurationThreshold{Duration: 24 * time.Hour},
+				Severity:          SeverityWarning,
+			},
+			current:  time.Date(2020, 1, 2, 1, 0, 0, 0, location),
+			rules:    []string{RuleDurationThreshold},
+			severity: SeverityWarning,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			vs := tt.tc.violations(tt.current, lastModified)
+			var rules []string
+			for _, v := range vs {
+				rules = append(rules, v.Rule)
+			}
+			assert.Equal(t, tt.rules, rules)
+			assert.Equal(t, tt.severity, FreshnessResult{Violations: vs}.HighestSeverity())
+		})
+	}
+}
+
+func TestMissingViolation(t *testing.T) {
+	location, _ := time.LoadLocation("Asia/Tokyo")
+	at := func(hour int) *TimeThreshold {
+		return &TimeThreshold{Time: time.Date(2020, 1, 1, hour, 0, 0, 0, location)}
+	}
+	current := time.Date(2020, 1, 1, 10, 0, 0, 0, location)
+
+	tests := map[string]struct {
+		tc TableConfig
+
+		// output
+		violated bool
+		severity Severity
+	}{
+		"no thresholds": {
+			tc:       TableConfig{},
+			violated: true,
+			severity: SeverityCritical,
+		},
+		"after time threshold": {
+			tc:       TableConfig{TimeThreshold: at(9), WarningTimeThreshold: at(8)},
+			violated: true,
+			severity: SeverityCritical,
+		},
+		"before time threshold": {
+			tc:       TableConfig{TimeThreshold: at(11)},
+			violated: false,
+		},
+		"before time threshold but after warning time threshold": {
+			tc:       TableConfig{TimeThreshold: at(11), WarningTimeThreshold: at(9)},
+			violated: true,
+			severity: SeverityWarning,
+		},
+		"only warning duration threshold": {
+			tc:       TableConfig{WarningDurationThreshold: &DurationThreshold{Duration: time.Hour}},
+			violated: true,
+			severity: SeverityWarning,
+		},
+		"before warning time threshold": {
+			tc:       TableConfig{WarningTimeThreshold: at(11)},
+			violated: false,
+		},
+		"after warning time threshold": {
+			tc:       TableConfig{WarningTimeThreshold: at(9)},
+			violated: true,
+			severity: SeverityWarning,
+		},
+		"severity of the table config": {
+			tc:       TableConfig{DurationThreshold: &DurationThreshold{Duration: time.Hour}, Severity: SeverityWarning},
+			violated: true,
+			severity: SeverityWarning,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			v, ok := tt.tc.missingViolation(current)
+			assert.Equal(t, tt.violated, ok)
+			assert.Equal(t, tt.severity, v.Severity)
+		})
+	}
+}
+
+func TestOwnership_Inherit(t *testing.T) {
+	parent := Ownership{Owner: "team-a", Channels: []string{"team-a-chat"}, Runbook: "https://example.com/runbook"}
+	tests := []struct {
+		o       Ownership
+		wantRes Ownership
+	}{
+		{o: Ownership{}, wantRes: parent},
+		{
+			o:       Ownership{Owner: "team-b"},
+			wantRes: Ownership{Owner: "team-b", Channels: []string{"team-a-chat"}, Runbook: "https://example.com/runbook"},
+		},
+		{
+			o:       Ownership{Channels: []string{"team-b-chat"}, Runbook: "https://example.com/other"},
+			wantRes: Ownership{Owner: "team-a", Channels: []string{"team-b-chat"}, Runbook: "https://example.com/other"},
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.wantRes, tt.o.inherit(parent))
+	}
+}
+
+func TestReasonTemplates_Apply(t *testing.T) {
+	checkedAt := time.Date(2020, 1, 2, 10, 0, 0, 0, time.Local)
+	report := Report{
+		CheckedAt: checkedAt,
+		Results: []FreshnessResult{
+			{
+				Project:           "pj",
+				Dataset:           "ds",
+				TableID:           "table",
+				Status:            StatusStale,
+				LastModifiedTime:  checkedAt.Add(-90 * time.Minute),
+				Ownership:         Ownership{Owner: "data-platform", Runbook: "https://example.com/runbook"},
+				DurationThreshold: &DurationThreshold{time.Hour},
+				TimeThreshold:     &TimeThreshold{time.Date(2020, 1, 2, 9, 0, 0, 0, time.Local)},
+				Reason:            []string{"time", "duration"},
+				Violations: []Violation{
+					{Rule: RuleTimeThreshold, Reason: "time", Severity: SeverityCritical},
+					{Rule: RuleDurationThreshold, Reason: "duration", Severity: SeverityCritical},
+				},
+			},
+			{Project: "pj", Dataset: "ds", TableID: "fresh", Status: StatusFresh},
+		},
+	}
+
+	tmpls := ReasonTemplates{
+		RuleDurationThreshold: `{{.TableID}} is {{.Elapsed}} old (threshold: {{.Threshold}}), ask {{.Owner}}: {{.Runbook}}`,
+	}
+	actual, err := tmpls.Apply(report)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"time", "table is 1h30m0s old (threshold: 1h0m0s), ask data-platform: https://example.com/runbook"}, actual.Results[0].Reason)
+	assert.Equal(t, "table is 1h30m0s old (threshold: 1h0m0s), ask data-platform: https://example.com/runbook", actual.Results[0].Violations[1].Reason)
+	assert.Equal(t, "duration", report.Results[0].Violations[1].Reason, "the original report should not be modified")
+	assert.Equal(t, report.Results[1], actual.Results[1])
+
+	_, err = ReasonTemplates{RuleTimeThreshold: "{{.Unknown"}.Apply(report)
+	assert.Error(t, err)
+}
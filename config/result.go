@@ -0,0 +1,244 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Status represents freshness status of a table.
+type Status string
+
+const (
+	StatusFresh   Status = "fresh"
+	StatusStale   Status = "stale"
+	StatusMissing Status = "missing"
+	StatusError   Status = "error"
+)
+
+// Severity represents urgency of an old table.
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// higher returns true if s is more urgent than o.
+func (s Severity) higher(o Severity) bool {
+	rank := map[Severity]int{"": 0, SeverityWarning: 1, SeverityCritical: 2}
+	return rank[s] > rank[o]
+}
+
+// Rules checked for each table.
+const (
+	RuleExists                   = "exists"
+	RuleTimeThreshold            = "time_threshold"
+	RuleDurationThreshold        = "duration_threshold"
+	RuleWarningTimeThreshold     = "warning_time_threshold"
+	RuleWarningDurationThreshold = "warning_duration_threshold"
+)
+
+// Rules is a list of all rules checked for each table.
+var Rules = []string{RuleExists, RuleTimeThreshold, RuleDurationThreshold, RuleWarningTimeThreshold, RuleWarningDurationThreshold}
+
+// Violation is a rule which a table violates.
+type Violation struct {
+	Rule     string   `json:"rule"`
+	Reason   string   `json:"reason"`
+	Severity Severity `json:"severity"`
+}
+
+func reasons(vs []Violation) (reason []string) {
+	for _, v := range vs {
+		reason = append(reason, v.Reason)
+	}
+	return reason
+}
+
+// FreshnessResult is a result of freshness check for a table.
+type FreshnessResult struct {
+	Table            string      `json:"table"`
+	ConfigTable      string      `json:"config_table"` // Table of TableConfig, prefix of the table if sharded
+	Project          string      `json:"project"`
+	Dataset          string      `json:"dataset"`
+	TableID          string      `json:"table_id"`
+	Status           Status      `json:"status"`
+	Severity         Severity    `json:"severity"` // severity of the table config
+	LastModifiedTime time.Time   `json:"last_modified_time,omitempty"`
+	NumRows          uint64      `json:"num_rows"`
+	Reason           []string    `json:"reason,omitempty"`
+	Violations       []Violation `json:"violations,omitempty"`
+	SilencedBy       string      `json:"silenced_by,omitempty"` // silence or maintenance window suppressing alerts
+	StaleSince       time.Time   `json:"stale_since,omitempty"` // time when the table was first observed not fresh in a row, only if alert states are tracked
+	ConsecutiveStale int         `json:"consecutive_stale,omitempty"`
+	ConsecutiveFresh int         `json:"consecutive_fresh,omitempty"`
+	Ownership
+
+	TimeThreshold            *TimeThreshold     `json:"time_threshold,omitempty"`
+	DurationThreshold        *DurationThreshold `json:"duration_threshold,omitempty"`
+	WarningTimeThreshold     *TimeThreshold     `json:"warning_time_threshold,omitempty"`
+	WarningDurationThreshold *DurationThreshold `json:"warning_duration_threshold,omitempty"`
+
+	Group   string            `json:"group,omitempty"`   // "dataset" or "owner" if the result represents a group of tables
+	Members []FreshnessResult `json:"members,omitempty"` // old tables collapsed into the result
+}
+
+// IsGroup returns true if the result represents a group of tables instead of a table.
+func (r FreshnessResult) IsGroup() bool {
+	return r.Group != ""
+}
+
+// FullTableID returns table ID in the form of "project.dataset.table".
+// It is "dataset:project.dataset" or "owner:OWNER" for a group.
+func (r FreshnessResult) FullTableID() string {
+	switch r.Group {
+	case "":
+		return fmt.Sprintf("%s.%s.%s", r.Project, r.Dataset, r.TableID)
+	case "dataset":
+		return fmt.Sprintf("dataset:%s.%s", r.Project, r.Dataset)
+	default:
+		return r.Group + ":" + r.Owner
+	}
+}
+
+// Key returns ID of the table config in the form of "project.dataset.table".
+// It is the same for all shards of a sharded table, and the same as FullTableID for a group.
+func (r FreshnessResult) Key() string {
+	if r.IsGroup() {
+		return r.FullTableID()
+	}
+	return fmt.Sprintf("%s.%s.%s", r.Project, r.Dataset, r.ConfigTable)
+}
+
+// ConsoleURL returns URL of the table on BigQuery console, or empty for a group.
+func (r FreshnessResult) ConsoleURL() string {
+	if r.IsGroup() {
+		return ""
+	}
+	return fmt.Sprintf("https://console.cloud.google.com/bigquery?project=%s&ws=!1m5!1m4!4m3!1s%s!2s%s!3s%s",
+		url.QueryEscape(r.Project), url.PathEscape(r.Project), url.PathEscape(r.Dataset), url.PathEscape(r.TableID))
+}
+
+// Report is a set of freshness results checked at the same time.
+type Report struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Results   []FreshnessResult `json:"results"`
+	Recovered []FreshnessResult `json:"recovered,omitempty"` // tables which became fresh since the last notification
+	GroupBy   string            `json:"group_by,omitempty"`  // key to group results, "dataset" or "owner"
+	Totals    map[Status]int    `json:"totals,omitempty"`    // number of all tables checked in the run for each status, see Counts
+}
+
+// ResultGroup is a group of results which have the same key.
+type ResultGroup struct {
+	Key     string
+	Results []FreshnessResult
+}
+
+// Groups returns results which are not fresh grouped by GroupBy in order of their first appearance.
+// All results are in one group if GroupBy is empty.
+func (r Report) Groups() []ResultGroup {
+	groups := make([]ResultGroup, 0)
+	index := make(map[string]int)
+	for _, res := range r.Failures() {
+		var key string
+		switch r.GroupBy {
+		case "project":
+			key = res.Project
+		case "dataset":
+			key = res.Project + "." + res.Dataset
+		case "owner":
+			key = res.Owner
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ResultGroup{Key: key})
+		}
+		groups[i].Results = append(groups[i].Results, res)
+	}
+	return groups
+}
+
+// SortResults sorts results by their table IDs.
+func SortResults(results []FreshnessResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].FullTableID() < results[j].FullTableID()
+	})
+}
+
+// TotalCounts returns Totals, or Counts if they are not set.
+// Totals are kept when results are filtered, e.g. for notifiers, so they should be preferred to count all tables checked in the run.
+func (r Report) TotalCounts() map[Status]int {
+	if r.Totals != nil {
+		return r.Totals
+	}
+	return r.Counts()
+}
+
+// Counts returns the number of tables for each status.
+// Results of groups are counted by their members.
+func (r Report) Counts() map[Status]int {
+	counts := map[Status]int{
+		StatusFresh:   0,
+		StatusStale:   0,
+		StatusMissing: 0,
+		StatusError:   0,
+	}
+	for _, res := range r.Results {
+		if !res.IsGroup() {
+			counts[res.Status]++
+		}
+		for _, m := range res.Members {
+			counts[m.Status]++
+		}
+	}
+	return counts
+}
+
+// HighestSeverity returns the most urgent severity of the violations, or empty if no rule is violated.
+// Tables which failed to be checked are critical.
+func (r FreshnessResult) HighestSeverity() Severity {
+	if r.Status == StatusError {
+		return SeverityCritical
+	}
+	var highest Severity
+	for _, v := range r.Violations {
+		if v.Severity.higher(highest) {
+			highest = v.Severity
+		}
+	}
+	return highest
+}
+
+// RuleSeverity returns the severity of alerts of the rule, whether it is violated or not.
+func (r FreshnessResult) RuleSeverity(rule string) Severity {
+	for _, v := range r.Violations {
+		if v.Rule == rule {
+			return v.Severity
+		}
+	}
+	if rule == RuleWarningTimeThreshold || rule == RuleWarningDurationThreshold {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+// Silenced returns true if alerts of the table are suppressed.
+func (r FreshnessResult) Silenced() bool {
+	return r.SilencedBy != ""
+}
+
+// Failures returns results which are not fresh.
+func (r Report) Failures() []FreshnessResult {
+	failures := make([]FreshnessResult, 0)
+	for _, res := range r.Results {
+		if res.Status != StatusFresh {
+			failures = append(failures, res)
+		}
+	}
+	return failures
+}
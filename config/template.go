@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// TemplateFuncs are functions available in all templates of tblmonit.
+var TemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": func(sep string, s []string) string {
+		return strings.Join(s, sep)
+	},
+}
+
+// ReasonTemplates are Go text/templates of reasons keyed by rules.
+// Reasons of the rules without templates are the built-in sentences.
+type ReasonTemplates map[string]string
+
+// ReasonData is data passed to reason templates.
+type ReasonData struct {
+	FreshnessResult
+	Violation Violation
+	Threshold string        // threshold of the rule, "15:04" for time thresholds
+	Elapsed   time.Duration // time since the table was last modified
+	CheckedAt time.Time
+}
+
+// Apply returns the report whose reasons of violations are rendered by the templates.
+func (t ReasonTemplates) Apply(report Report) (Report, error) {
+	if len(t) == 0 {
+		return report, nil
+	}
+
+	tmpls := make(map[string]*template.Template, len(t))
+	for rule, text := range t {
+		tmpl, err := template.New(rule).Funcs(TemplateFuncs).Parse(text)
+		if err != nil {
+			return Report{}, xerrors.Errorf("failed to parse reason template of %s: %w", rule, err)
+		}
+		tmpls[rule] = tmpl
+	}
+
+	results := make([]FreshnessResult, 0, len(report.Results))
+	for _, r := range report.Results {
+		if len(r.Violations) == 0 {
+			results = append(results, r)
+			continue
+		}
+
+		vs := make([]Violation, 0, len(r.Violations))
+		for _, v := range r.Violations {
+			tmpl, ok := tmpls[v.Rule]
+			if !ok {
+				vs = append(vs, v)
+				continue
+			}
+
+			data := ReasonData{
+				FreshnessResult: r,
+				Violation:       v,
+				Threshold:       r.threshold(v.Rule),
+				CheckedAt:       report.CheckedAt,
+			}
+			if !r.LastModifiedTime.IsZero() {
+				data.Elapsed = report.CheckedAt.Sub(r.LastModifiedTime)
+			}
+			buf := new(bytes.Buffer)
+			if err := tmpl.Execute(buf, data); err != nil {
+				return Report{}, xerrors.Errorf("failed to render reason of %s: %w", r.FullTableID(), err)
+			}
+			v.Reason = buf.String()
+			vs = append(vs, v)
+		}
+		r.Violations = vs
+		r.Reason = reasons(vs)
+		results = append(results, r)
+	}
+	report.Results = results
+	return report, nil
+}
+
+// threshold returns the threshold of the rule in the form of the built-in reasons.
+func (r FreshnessResult) threshold(rule string) string {
+	switch rule {
+	case RuleTimeThreshold:
+		if r.TimeThreshold != nil {
+			return r.TimeThreshold.Format("15:04")
+		}
+	case RuleWarningTimeThreshold:
+		if r.WarningTimeThreshold != nil {
+			return r.WarningTimeThreshold.Format("15:04")
+		}
+	case RuleDurationThreshold:
+		if r.DurationThreshold != nil {
+			return r.DurationThreshold.String()
+		}
+	case RuleWarningDurationThreshold:
+		if r.WarningDurationThreshold != nil {
+			return r.WarningDurationThreshold.String()
+		}
+	}
+	return ""
+}
@@ -2,12 +2,12 @@ package flexconfig
 
 import (
 	"context"
-	"fmt"
 	"regexp"
 	"time"
 
 	bq "cloud.google.com/go/bigquery"
 	"github.com/hirosassa/tblmonit/config"
+	"github.com/hirosassa/tblmonit/telemetry"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/xerrors"
 	"google.golang.org/api/iterator"
@@ -18,34 +18,49 @@ type FlexConfig struct {
 }
 
 type FlexProject struct {
-	ID          string // project ID, DO NOT support regular expression
+	ID string // project ID, DO NOT support regular expression
+	config.Ownership
 	Dataset     []config.Dataset
 	FlexDataset []FlexDataset
 }
 
 type FlexDataset struct {
-	ID              string // specify dataset id, supports regular expression
+	ID string // specify dataset id, supports regular expression
+	config.Ownership
 	TableConfig     []config.TableConfig
 	FlexTableConfig []FlexTableConfig
 }
 
 type FlexTableConfig struct {
-	Table             string
-	FlexTable         string
-	DateForShards     string
-	TimeThreshold     *config.TimeThreshold
-	DurationThreshold *config.DurationThreshold
+	Table                    string
+	FlexTable                string
+	DateForShards            string
+	TimeThreshold            *config.TimeThreshold
+	DurationThreshold        *config.DurationThreshold
+	WarningTimeThreshold     *config.TimeThreshold
+	WarningDurationThreshold *config.DurationThreshold
+	Severity                 config.Severity
+	ConsecutiveStale         int
+	ConsecutiveFresh         int
+	config.Ownership
 }
 
 // Expand returns config.Config defined by given FlexConfig
 func (c *FlexConfig) Expand() (cfg config.Config, err error) {
-	fmt.Println(c)
+	return c.ExpandContext(context.Background())
+}
+
+// ExpandContext is Expand with a context.
+// Spans of each project and listing of datasets and tables are recorded if ctx has a tracer.
+func (c *FlexConfig) ExpandContext(ctx context.Context) (cfg config.Config, err error) {
+	ctx, span := telemetry.Start(ctx, "Expand", telemetry.SpanKindInternal)
+	defer span.Finish()
 
-	ctx := context.Background()
 	pjs := make([]config.Project, 0, len(c.FlexProject))
 	for _, p := range c.FlexProject {
 		pj, err := p.expand(ctx)
 		if err != nil {
+			span.RecordError(err)
 			return config.Config{}, xerrors.Errorf("failed to expand flex project: %w", err)
 		}
 		pjs = append(pjs, pj)
@@ -59,6 +74,12 @@ func (c *FlexConfig) Expand() (cfg config.Config, err error) {
 }
 
 func (p *FlexProject) expand(ctx context.Context) (pj config.Project, err error) {
+	ctx, span := telemetry.Start(ctx, "project "+p.ID, telemetry.SpanKindInternal, telemetry.String("bigquery.project", p.ID))
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+
 	client, err := bq.NewClient(ctx, p.ID)
 	if err != nil {
 		return config.Project{}, xerrors.Errorf("failed to create client: %w", err)
@@ -80,14 +101,19 @@ func (p *FlexProject) expand(ctx context.Context) (pj config.Project, err error)
 	log.Info().Msgf("p.ID: %s", p.ID)
 
 	return config.Project{
-		ID:      p.ID,
-		Dataset: dss,
+		ID:        p.ID,
+		Ownership: p.Ownership,
+		Dataset:   dss,
 	}, nil
 }
 
 func (d *FlexDataset) expand(ctx context.Context, c *bq.Client) (ds []config.Dataset, err error) {
-	dsiter := c.Datasets(ctx)
+	lsCtx, span := telemetry.Start(ctx, "bigquery.datasets.list", telemetry.SpanKindClient, telemetry.String("tblmonit.pattern", d.ID))
+	dsiter := c.Datasets(lsCtx)
 	datasets, err := d.filterDataset(dsiter)
+	span.SetAttributes(telemetry.Int("tblmonit.matched", len(datasets)))
+	span.RecordError(err)
+	span.Finish()
 	if err != nil {
 		return []config.Dataset{}, xerrors.Errorf("failed to fetch datasets: %w", err)
 	}
@@ -107,6 +133,7 @@ func (d *FlexDataset) expand(ctx context.Context, c *bq.Client) (ds []config.Dat
 
 		ds = append(ds, config.Dataset{
 			ID:          dataset.DatasetID,
+			Ownership:   d.Ownership,
 			TableConfig: tss,
 		})
 	}
@@ -115,8 +142,13 @@ func (d *FlexDataset) expand(ctx context.Context, c *bq.Client) (ds []config.Dat
 }
 
 func (t *FlexTableConfig) expand(ctx context.Context, ds *bq.Dataset) (tc []config.TableConfig, err error) {
-	titer := ds.Tables(ctx)
+	lsCtx, span := telemetry.Start(ctx, "bigquery.tables.list", telemetry.SpanKindClient,
+		telemetry.String("bigquery.project", ds.ProjectID), telemetry.String("bigquery.dataset", ds.DatasetID), telemetry.String("tblmonit.pattern", t.Table))
+	titer := ds.Tables(lsCtx)
 	tables, err := t.filterTable(titer)
+	span.SetAttributes(telemetry.Int("tblmonit.matched", len(tables)))
+	span.RecordError(err)
+	span.Finish()
 	if err != nil {
 		return []config.TableConfig{}, xerrors.Errorf("failed to fetch tables: %w", err)
 	}
@@ -129,16 +161,28 @@ func (t *FlexTableConfig) expand(ctx context.Context, ds *bq.Dataset) (tc []conf
 			processed[table] = struct{}{}
 			if table == tb.TableID { // non-sharded table (without DateForShards)
 				ts = append(ts, config.TableConfig{
-					Table:             table,
-					TimeThreshold:     t.TimeThreshold,
-					DurationThreshold: t.DurationThreshold,
+					Table:                    table,
+					TimeThreshold:            t.TimeThreshold,
+					DurationThreshold:        t.DurationThreshold,
+					WarningTimeThreshold:     t.WarningTimeThreshold,
+					WarningDurationThreshold: t.WarningDurationThreshold,
+					Severity:                 t.Severity,
+					ConsecutiveStale:         t.ConsecutiveStale,
+					ConsecutiveFresh:         t.ConsecutiveFresh,
+					Ownership:                t.Ownership,
 				})
 			} else { // sharded table
 				ts = append(ts, config.TableConfig{
-					Table:             table,
-					DateForShards:     t.DateForShards,
-					TimeThreshold:     t.TimeThreshold,
-					DurationThreshold: t.DurationThreshold,
+					Table:                    table,
+					DateForShards:            t.DateForShards,
+					TimeThreshold:            t.TimeThreshold,
+					DurationThreshold:        t.DurationThreshold,
+					WarningTimeThreshold:     t.WarningTimeThreshold,
+					WarningDurationThreshold: t.WarningDurationThreshold,
+					Severity:                 t.Severity,
+					ConsecutiveStale:         t.ConsecutiveStale,
+					ConsecutiveFresh:         t.ConsecutiveFresh,
+					Ownership:                t.Ownership,
 				})
 			}
 		}
@@ -199,9 +243,10 @@ func (t *FlexTableConfig) filterTable(titer *bq.TableIterator) (tables []*bq.Tab
 	return tables, nil
 }
 
-// isValid returns false if both TimeThreshold and DurationThreshold is not configured
+// isValid returns false if none of TimeThreshold, DurationThreshold and their warning variants is configured
 func (t *FlexTableConfig) isValid() bool {
-	timeThresholdIsNil := t.TimeThreshold == nil || t.TimeThreshold.Time == time.Time{}
-	durationThresholdIsNil := t.DurationThreshold == nil || t.DurationThreshold.Duration == 0
-	return !timeThresholdIsNil || !durationThresholdIsNil
+	timeThresholdIsNil := func(th *config.TimeThreshold) bool { return th == nil || th.Time == time.Time{} }
+	durationThresholdIsNil := func(th *config.DurationThreshold) bool { return th == nil || th.Duration == 0 }
+	return !timeThresholdIsNil(t.TimeThreshold) || !durationThresholdIsNil(t.DurationThreshold) ||
+		!timeThresholdIsNil(t.WarningTimeThreshold) || !durationThresholdIsNil(t.WarningDurationThreshold)
 }
@@ -71,6 +71,13 @@ func TestFlexTableConfig_IsValid(t *testing.T) {
 			wantRes: true,
 			desc:    "both required fields are filled",
 		},
+		{
+			ft: FlexTableConfig{
+				WarningDurationThreshold: &config.DurationThreshold{Duration: 12345},
+			},
+			wantRes: true,
+			desc:    "only warning Duration is filled",
+		},
 	}
 	for _, tt := range tests {
 		actual := tt.ft.isValid()
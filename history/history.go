@@ -0,0 +1,176 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+// Config is a configuration of the history store on the settings file.
+type Config struct {
+	Path       string        // path to the database file, history is not recorded if empty
+	Retention  time.Duration // records older than this are removed, kept forever if zero
+	MaxRecords int           // maximum number of records for each table, unlimited if zero
+}
+
+// Record is a freshness result of a table observed on a run.
+type Record struct {
+	CheckedAt        time.Time     `json:"checked_at"`
+	TableID          string        `json:"table_id"`
+	Status           config.Status `json:"status"`
+	LastModifiedTime time.Time     `json:"last_modified_time,omitempty"`
+	NumRows          uint64        `json:"num_rows"`
+	Reason           []string      `json:"reason,omitempty"`
+}
+
+var historyBucket = []byte("history")
+
+// Store is an embedded database of freshness results.
+// Records are stored in a bucket for each table config keyed by the time of the run.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the history store on path, creating it if it doesn't exist.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open history store %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// OpenReadOnly opens the existing history store on path to read records.
+// It takes a shared lock, so that the store can be read by other processes at the same time.
+func OpenReadOnly(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open history store %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save records all results on the report.
+func (s *Store) Save(report config.Report) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range report.Results {
+			b, err := root.CreateBucketIfNotExists([]byte(r.Key()))
+			if err != nil {
+				return err
+			}
+
+			v, err := json.Marshal(Record{
+				CheckedAt:        report.CheckedAt,
+				TableID:          r.TableID,
+				Status:           r.Status,
+				LastModifiedTime: r.LastModifiedTime,
+				NumRows:          r.NumRows,
+				Reason:           r.Reason,
+			})
+			if err != nil {
+				return xerrors.Errorf("failed to encode record: %w", err)
+			}
+			if err := b.Put(timeKey(report.CheckedAt), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Timeline returns the latest records of the table config in chronological order.
+// key is in the form of "project.dataset.table", all records are returned if limit is zero.
+func (s *Store) Timeline(key string, limit int) ([]Record, error) {
+	records := make([]Record, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(historyBucket)
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(key))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && (limit == 0 || len(records) < limit); k, v = c.Prev() {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return xerrors.Errorf("failed to decode record: %w", err)
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// Prune removes records exceeding retention settings, and returns the number of removed records.
+func (s *Store) Prune(cfg Config, current time.Time) (removed int, err error) {
+	if cfg.Retention == 0 && cfg.MaxRecords == 0 {
+		return 0, nil
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(historyBucket)
+		if root == nil {
+			return nil
+		}
+
+		return root.ForEach(func(name, _ []byte) error {
+			b := root.Bucket(name)
+			var expired [][]byte
+			kept := 0
+			c := b.Cursor()
+			for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+				tooOld := cfg.Retention > 0 && keyTime(k).Before(current.Add(-cfg.Retention))
+				tooMany := cfg.MaxRecords > 0 && kept >= cfg.MaxRecords
+				if !tooOld && !tooMany {
+					kept++
+					continue
+				}
+				expired = append(expired, k)
+			}
+
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			removed += len(expired)
+			return nil
+		})
+	})
+	return removed, err
+}
+
+// timeKey returns a key which sorts in chronological order.
+func timeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+func keyTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+}
@@ -0,0 +1,107 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func report(checkedAt time.Time, status config.Status) config.Report {
+	return config.Report{
+		CheckedAt: checkedAt,
+		Results: []config.FreshnessResult{
+			{
+				Project:     "pj",
+				Dataset:     "ds",
+				ConfigTable: "sharded_on_",
+				TableID:     "sharded_on_" + checkedAt.Format("20060102"),
+				Status:      status,
+				NumRows:     10,
+			},
+		},
+	}
+}
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	base := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		status := config.StatusFresh
+		if i%2 == 1 {
+			status = config.StatusStale
+		}
+		assert.NoError(t, s.Save(report(base.AddDate(0, 0, i), status)))
+	}
+
+	records, err := s.Timeline("pj.ds.sharded_on_", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(records))
+	assert.Equal(t, "sharded_on_20200101", records[0].TableID)
+	assert.Equal(t, config.StatusStale, records[1].Status)
+
+	records, err = s.Timeline("pj.ds.sharded_on_", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sharded_on_20200104", "sharded_on_20200105"}, []string{records[0].TableID, records[1].TableID})
+
+	records, err = s.Timeline("pj.ds.unknown", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(records))
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	_, err := OpenReadOnly(path)
+	assert.Error(t, err, "the store must exist")
+
+	s, err := Open(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Save(report(time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC), config.StatusFresh)))
+	assert.NoError(t, s.Close())
+
+	// read-only stores can be opened at the same time
+	r1, err := OpenReadOnly(path)
+	assert.NoError(t, err)
+	defer r1.Close()
+	r2, err := OpenReadOnly(path)
+	assert.NoError(t, err)
+	defer r2.Close()
+
+	records, err := r2.Timeline("pj.ds.sharded_on_", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Error(t, r1.Save(report(time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC), config.StatusFresh)))
+}
+
+func TestStore_Prune(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		removed int
+		desc    string
+	}{
+		{cfg: Config{}, removed: 0, desc: "no retention settings"},
+		{cfg: Config{Retention: 36 * time.Hour}, removed: 3, desc: "records older than 36h are removed"},
+		{cfg: Config{MaxRecords: 4}, removed: 1, desc: "the oldest record is removed"},
+		{cfg: Config{Retention: 72 * time.Hour, MaxRecords: 1}, removed: 4, desc: "both settings are applied"},
+	}
+	base := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+		assert.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, s.Save(report(base.AddDate(0, 0, i), config.StatusFresh)))
+		}
+
+		removed, err := s.Prune(tt.cfg, base.AddDate(0, 0, 4))
+		assert.NoError(t, err, tt.desc)
+		assert.Equal(t, tt.removed, removed, tt.desc)
+		records, _ := s.Timeline("pj.ds.sharded_on_", 0)
+		assert.Equal(t, 5-tt.removed, len(records), tt.desc)
+		s.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+const (
+	cloudMonitoringMetricPrefix = "custom.googleapis.com/tblmonit/"
+
+	// maxTimeSeriesPerRequest is the maximum number of time series in a request of Cloud Monitoring API.
+	maxTimeSeriesPerRequest = 200
+)
+
+// CloudMonitoringConfig is a configuration of Cloud Monitoring sink on the settings file.
+type CloudMonitoringConfig struct {
+	Project string // project where time series are written, metrics are not written if empty
+}
+
+// cloudMonitoringMetric is a custom metric of tables written to Cloud Monitoring.
+type cloudMonitoringMetric struct {
+	name        string
+	description string
+	valueType   string
+	unit        string
+}
+
+var (
+	cloudMonitoringStale = cloudMonitoringMetric{
+		name:        "table/stale",
+		description: "Whether the table is not fresh (1) or not (0).",
+		valueType:   "INT64",
+		unit:        "1",
+	}
+	cloudMonitoringAge = cloudMonitoringMetric{
+		name:        "table/age_seconds",
+		description: "Seconds since the table was last modified.",
+		valueType:   "DOUBLE",
+		unit:        "s",
+	}
+	cloudMonitoringNumRows = cloudMonitoringMetric{
+		name:        "table/num_rows",
+		description: "Number of rows of the table.",
+		valueType:   "INT64",
+		unit:        "1",
+	}
+)
+
+func (m cloudMonitoringMetric) metricType() string {
+	return cloudMonitoringMetricPrefix + m.name
+}
+
+func (m cloudMonitoringMetric) descriptor() *monitoring.MetricDescriptor {
+	label := func(key, description string) *monitoring.LabelDescriptor {
+		return &monitoring.LabelDescriptor{Key: key, ValueType: "STRING", Description: description}
+	}
+	return &monitoring.MetricDescriptor{
+		Type:        m.metricType(),
+		MetricKind:  "GAUGE",
+		ValueType:   m.valueType,
+		Unit:        m.unit,
+		Description: m.description,
+		DisplayName: "tblmonit " + m.name,
+		Labels: []*monitoring.LabelDescriptor{
+			label("project", "Project ID of the table."),
+			label("dataset", "Dataset ID of the table."),
+			label("table", "Table ID of the table config, which is the prefix for sharded tables."),
+		},
+	}
+}
+
+// CloudMonitoring writes freshness of tables to Cloud Monitoring as custom metrics.
+type CloudMonitoring struct {
+	project     string
+	service     *monitoring.Service
+	descriptors map[string]bool // metric types whose descriptors are known to exist
+}
+
+// NewCloudMonitoring returns CloudMonitoring which writes time series to the project.
+// opts are passed to the client of Cloud Monitoring API, e.g. option.WithEndpoint for a stub.
+func NewCloudMonitoring(ctx context.Context, project string, opts ...option.ClientOption) (*CloudMonitoring, error) {
+	service, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create cloud monitoring client: %w", err)
+	}
+	return &CloudMonitoring{project: project, service: service, descriptors: make(map[string]bool)}, nil
+}
+
+// cloudMonitoringTimeSeries returns time series of each table config in the report, see tableResults.
+func cloudMonitoringTimeSeries(report config.Report) []*monitoring.TimeSeries {
+	interval := &monitoring.TimeInterval{EndTime: report.CheckedAt.UTC().Format(time.RFC3339Nano)}
+	series := func(m cloudMonitoringMetric, r config.FreshnessResult, value *monitoring.TypedValue) *monitoring.TimeSeries {
+		return &monitoring.TimeSeries{
+			Metric: &monitoring.Metric{
+				Type:   m.metricType(),
+				Labels: map[string]string{"project": r.Project, "dataset": r.Dataset, "table": r.ConfigTable},
+			},
+			Resource:   &monitoring.MonitoredResource{Type: "global"},
+			MetricKind: "GAUGE",
+			ValueType:  m.valueType,
+			Points:     []*monitoring.Point{{Interval: interval, Value: value}},
+		}
+	}
+	int64Value := func(v int64) *monitoring.TypedValue { return &monitoring.TypedValue{Int64Value: &v} }
+	doubleValue := func(v float64) *monitoring.TypedValue { return &monitoring.TypedValue{DoubleValue: &v} }
+
+	ts := make([]*monitoring.TimeSeries, 0)
+	for _, r := range tableResults(report) {
+		stale := int64(0)
+		if len(r.Violations) > 0 {
+			stale = 1
+		}
+		ts = append(ts, series(cloudMonitoringStale, r, int64Value(stale)))
+		if !r.LastModifiedTime.IsZero() {
+			ts = append(ts,
+				series(cloudMonitoringAge, r, doubleValue(report.CheckedAt.Sub(r.LastModifiedTime).Seconds())),
+				series(cloudMonitoringNumRows, r, int64Value(int64(r.NumRows))),
+			)
+		}
+	}
+	return ts
+}
+
+// Write writes time series of tables on the report.
+// Metric descriptors are created on first use.
+func (c *CloudMonitoring) Write(ctx context.Context, report config.Report) error {
+	for _, m := range []cloudMonitoringMetric{cloudMonitoringStale, cloudMonitoringAge, cloudMonitoringNumRows} {
+		if err := c.ensureDescriptor(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	ts := cloudMonitoringTimeSeries(report)
+	for start := 0; start < len(ts); start += maxTimeSeriesPerRequest {
+		end := start + maxTimeSeriesPerRequest
+		if end > len(ts) {
+			end = len(ts)
+		}
+		req := &monitoring.CreateTimeSeriesRequest{TimeSeries: ts[start:end]}
+		if _, err := c.service.Projects.TimeSeries.Create("projects/"+c.project, req).Context(ctx).Do(); err != nil {
+			return xerrors.Errorf("failed to write time series: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureDescriptor creates the descriptor of the metric unless it exists.
+func (c *CloudMonitoring) ensureDescriptor(ctx context.Context, m cloudMonitoringMetric) error {
+	if c.descriptors[m.metricType()] {
+		return nil
+	}
+
+	name := "projects/" + c.project + "/metricDescriptors/" + m.metricType()
+	_, err := c.service.Projects.MetricDescriptors.Get(name).Context(ctx).Do()
+	if config.IsNotFound(err) {
+		_, err = c.service.Projects.MetricDescriptors.Create("projects/"+c.project, m.descriptor()).Context(ctx).Do()
+		if err != nil {
+			return xerrors.Errorf("failed to create metric descriptor %s: %w", m.metricType(), err)
+		}
+	} else if err != nil {
+		return xerrors.Errorf("failed to get metric descriptor %s: %w", m.metricType(), err)
+	}
+
+	c.descriptors[m.metricType()] = true
+	return nil
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+// ReportFamilies returns metrics of tables on the report and the time of the check.
+func ReportFamilies(report config.Report) []Family {
+	return append(TableFamilies(report), lastCheckFamily(report.CheckedAt))
+}
+
+func lastCheckFamily(t time.Time) Family {
+	return Family{
+		Name:    "tblmonit_last_check_timestamp_seconds",
+		Help:    "Time of the last successful check in unix time.",
+		Type:    Gauge,
+		Samples: []Sample{{Value: unixSeconds(t)}},
+	}
+}
+
+// WriteTextfile writes the families to path in the format of node_exporter textfile collector.
+// The file is replaced atomically, so the collector never reads a partially written file.
+func WriteTextfile(path string, families []Family) (err error) {
+	// the temporary file doesn't end with ".prom", so that the collector ignores it
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return xerrors.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err := WriteText(f, families); err != nil {
+		f.Close()
+		return xerrors.Errorf("failed to write metrics: %w", err)
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return xerrors.Errorf("failed to change mode of %s: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("failed to write metrics: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return xerrors.Errorf("failed to rename %s to %s: %w", f.Name(), path, err)
+	}
+	return nil
+}
+
+// Pusher pushes metrics to Prometheus Pushgateway.
+type Pusher struct {
+	URL      string  // URL of Pushgateway
+	Job      string  // job label of the grouping key
+	Grouping []Label // other labels of the grouping key such as instance
+	Client   *http.Client
+}
+
+// Push replaces metrics of the grouping key on Pushgateway with the families.
+func (p *Pusher) Push(ctx context.Context, families []Family) error {
+	if p.Job == "" {
+		return xerrors.New("job is required to push metrics")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, families); err != nil {
+		return xerrors.Errorf("failed to encode metrics: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.groupingURL(), &buf)
+	if err != nil {
+		return xerrors.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return xerrors.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return xerrors.Errorf("unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// groupingURL returns URL of the grouping key, see https://github.com/prometheus/pushgateway#url
+func (p *Pusher) groupingURL() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(p.URL, "/"))
+	b.WriteString("/metrics")
+	for _, l := range append([]Label{{Name: "job", Value: p.Job}}, p.Grouping...) {
+		b.WriteString("/" + l.Name)
+		// values which can't be a path segment are encoded by base64
+		if l.Value == "" || strings.Contains(l.Value, "/") {
+			b.WriteString("@base64/" + base64.RawURLEncoding.EncodeToString([]byte(l.Value)))
+			if l.Value == "" {
+				b.WriteString("=")
+			}
+			continue
+		}
+		b.WriteString("/" + url.PathEscape(l.Value))
+	}
+	return b.String()
+}
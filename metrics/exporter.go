@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/rs/zerolog/log"
+)
+
+// ContentType is the content type of Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter publishes metrics of the latest report and of tblmonit itself on HTTP.
+type Exporter struct {
+	mu        sync.Mutex
+	report    config.Report
+	checks    int
+	apiErrors int
+	duration  time.Duration
+}
+
+// Update replaces the report with the result of a check which took duration.
+// If the check failed, the previous report is kept.
+func (e *Exporter) Update(report config.Report, duration time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.checks++
+	e.duration = duration
+	if err != nil {
+		e.apiErrors++
+		return
+	}
+	e.report = report
+	e.apiErrors += report.Counts()[config.StatusError]
+}
+
+// Families returns metrics of tables on the latest report and metrics of tblmonit itself.
+func (e *Exporter) Families() []Family {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	families := TableFamilies(e.report)
+	if e.checks == 0 {
+		return families
+	}
+	if !e.report.CheckedAt.IsZero() {
+		families = append(families, lastCheckFamily(e.report.CheckedAt))
+	}
+	return append(families,
+		Family{
+			Name:    "tblmonit_check_duration_seconds",
+			Help:    "Duration of the last check of all tables.",
+			Type:    Gauge,
+			Samples: []Sample{{Value: e.duration.Seconds()}},
+		},
+		Family{
+			Name:    "tblmonit_checks_total",
+			Help:    "Number of checks run.",
+			Type:    Counter,
+			Samples: []Sample{{Value: float64(e.checks)}},
+		},
+		Family{
+			Name:    "tblmonit_api_errors_total",
+			Help:    "Number of errors of BigQuery API.",
+			Type:    Counter,
+			Samples: []Sample{{Value: float64(e.apiErrors)}},
+		},
+	)
+}
+
+// ServeHTTP writes the metrics in Prometheus text exposition format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := WriteText(w, e.Families()); err != nil {
+		log.Error().Err(err).Msg("failed to write metrics")
+	}
+}
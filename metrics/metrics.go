@@ -0,0 +1,95 @@
+// Package metrics converts freshness results into metrics, and exports them to monitoring systems.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is a type of a metric.
+type Type string
+
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+// Label is a pair of a label name and its value.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a metric with labels.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Family is a set of samples of the same metric.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// WriteText writes the families in Prometheus text exposition format.
+// Families without samples are omitted.
+func WriteText(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name)
+			if len(s.Labels) > 0 {
+				labels := make([]string, 0, len(s.Labels))
+				for _, l := range s.Labels {
+					labels = append(labels, fmt.Sprintf(`%s="%s"`, l.Name, escapeLabelValue(l.Value)))
+				}
+				fmt.Fprintf(bw, "{%s}", strings.Join(labels, ","))
+			}
+			fmt.Fprintf(bw, " %s\n", formatValue(s.Value))
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,298 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/option"
+)
+
+var sampleReport = config.Report{
+	CheckedAt: time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC),
+	Results: []config.FreshnessResult{
+		{
+			Project:           "pj",
+			Dataset:           "ds",
+			ConfigTable:       "sharded_",
+			TableID:           "sharded_20200101",
+			Status:            config.StatusStale,
+			Severity:          config.SeverityCritical,
+			LastModifiedTime:  time.Date(2020, 1, 2, 7, 0, 0, 0, time.UTC),
+			NumRows:           100,
+			Ownership:         config.Ownership{Owner: "data-platform"},
+			DurationThreshold: &config.DurationThreshold{Duration: time.Hour},
+			TimeThreshold:     &config.TimeThreshold{Time: time.Date(2020, 1, 2, 8, 0, 0, 0, time.UTC)},
+			Violations:        []config.Violation{{Rule: config.RuleDurationThreshold, Severity: config.SeverityCritical}},
+		},
+		{
+			Project:     "pj",
+			Dataset:     "ds",
+			ConfigTable: "missing",
+			TableID:     "missing",
+			Status:      config.StatusMissing,
+			Severity:    config.SeverityWarning,
+			Violations:  []config.Violation{{Rule: config.RuleExists, Severity: config.SeverityWarning}},
+		},
+		{Project: "pj", Dataset: "ds", ConfigTable: "error", TableID: "error", Status: config.StatusError},
+	},
+}
+
+func TestWriteText(t *testing.T) {
+	families := []Family{
+		{
+			Name: "test_metric",
+			Help: "Help with \\ and\nnewline.",
+			Type: Gauge,
+			Samples: []Sample{
+				{Labels: []Label{{Name: "a", Value: "quote \" backslash \\ newline \n"}}, Value: 1.5},
+				{Value: math.Inf(1)},
+			},
+		},
+		{Name: "empty_metric", Type: Counter},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteText(&buf, families))
+	assert.Equal(t, `# HELP test_metric Help with \\ and\nnewline.
+# TYPE test_metric gauge
+test_metric{a="quote \" backslash \\ newline \n"} 1.5
+test_metric +Inf
+`, buf.String())
+}
+
+func TestExporter(t *testing.T) {
+	e := &Exporter{}
+	e.Update(config.Report{}, time.Second, xerrors.New("failed"))
+	e.Update(sampleReport, 2*time.Second, nil)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP tblmonit_table_last_modified_timestamp_seconds Last modified time of the table in unix time.
+# TYPE tblmonit_table_last_modified_timestamp_seconds gauge
+tblmonit_table_last_modified_timestamp_seconds{project="pj",dataset="ds",table="sharded_",owner="data-platform"} 1.5779484e+09
+# HELP tblmonit_table_stale Whether the table violates the rule (1) or not (0).
+# TYPE tblmonit_table_stale gauge
+tblmonit_table_stale{project="pj",dataset="ds",table="sharded_",owner="data-platform",rule="exists",severity="critical"} 0
+tblmonit_table_stale{project="pj",dataset="ds",table="sharded_",owner="data-platform",rule="time_threshold",severity="critical"} 0
+tblmonit_table_stale{project="pj",dataset="ds",table="sharded_",owner="data-platform",rule="duration_threshold",severity="critical"} 1
+tblmonit_table_stale{project="pj",dataset="ds",table="missing",owner="",rule="exists",severity="warning"} 1
+# HELP tblmonit_table_num_rows Number of rows of the table.
+# TYPE tblmonit_table_num_rows gauge
+tblmonit_table_num_rows{project="pj",dataset="ds",table="sharded_",owner="data-platform"} 100
+# HELP tblmonit_table_time_threshold_timestamp_seconds Time of day by which the table should be created today in unix time.
+# TYPE tblmonit_table_time_threshold_timestamp_seconds gauge
+tblmonit_table_time_threshold_timestamp_seconds{project="pj",dataset="ds",table="sharded_",owner="data-platform",rule="time_threshold"} 1.577952e+09
+# HELP tblmonit_table_duration_threshold_seconds Duration in which the table should be modified.
+# TYPE tblmonit_table_duration_threshold_seconds gauge
+tblmonit_table_duration_threshold_seconds{project="pj",dataset="ds",table="sharded_",owner="data-platform",rule="duration_threshold"} 3600
+# HELP tblmonit_last_check_timestamp_seconds Time of the last successful check in unix time.
+# TYPE tblmonit_last_check_timestamp_seconds gauge
+tblmonit_last_check_timestamp_seconds 1.5779556e+09
+# HELP tblmonit_check_duration_seconds Duration of the last check of all tables.
+# TYPE tblmonit_check_duration_seconds gauge
+tblmonit_check_duration_seconds 2
+# HELP tblmonit_checks_total Number of checks run.
+# TYPE tblmonit_checks_total counter
+tblmonit_checks_total 2
+# HELP tblmonit_api_errors_total Number of errors of BigQuery API.
+# TYPE tblmonit_api_errors_total counter
+tblmonit_api_errors_total 2
+`, string(body))
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tblmonit.prom")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("old"), 0600))
+
+	assert.NoError(t, WriteTextfile(path, ReportFamilies(sampleReport)))
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `tblmonit_table_num_rows{project="pj",dataset="ds",table="sharded_",owner="data-platform"} 100`)
+	assert.Contains(t, string(b), "tblmonit_last_check_timestamp_seconds 1.5779556e+09\n")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files), "temporary files should be removed")
+}
+
+func TestPusher_Push(t *testing.T) {
+	var method, path, contentType string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, contentType = r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	p := Pusher{URL: srv.URL + "/", Job: "tblmonit", Grouping: []Label{{Name: "instance", Value: "host/1"}, {Name: "env", Value: ""}}}
+	assert.NoError(t, p.Push(context.Background(), ReportFamilies(sampleReport)))
+
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/tblmonit/instance@base64/aG9zdC8x/env@base64/=", path)
+	assert.Equal(t, ContentType, contentType)
+	assert.Contains(t, string(body), "# TYPE tblmonit_table_stale gauge\n")
+}
+
+func TestSendStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	receive := func() string {
+		buf := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	c := StatsDConfig{Address: conn.LocalAddr().String(), Tags: []string{"env:test"}}
+	assert.NoError(t, SendStatsD(c, sampleReport))
+	assert.Equal(t, `tblmonit.table.stale:1|g|#env:test,project:pj,dataset:ds,table:sharded_,owner:data-platform,severity:critical
+tblmonit.table.age_seconds:7200|g|#env:test,project:pj,dataset:ds,table:sharded_,owner:data-platform,severity:critical
+tblmonit.table.num_rows:100|g|#env:test,project:pj,dataset:ds,table:sharded_,owner:data-platform,severity:critical
+tblmonit.table.stale:1|g|#env:test,project:pj,dataset:ds,table:missing,severity:warning`, receive())
+
+	c = StatsDConfig{Address: conn.LocalAddr().String(), Prefix: "bq.", Format: "statsd"}
+	assert.NoError(t, SendStatsD(c, sampleReport))
+	assert.Equal(t, `bq.pj.ds.sharded_.stale:1|g
+bq.pj.ds.sharded_.age_seconds:7200|g
+bq.pj.ds.sharded_.num_rows:100|g
+bq.pj.ds.missing.stale:1|g`, receive())
+}
+
+func TestStatsDGauges_SeverityTag(t *testing.T) {
+	// the severity tag is the configured one whether the table is fresh or violates a warning rule,
+	// so that the series of the table don't change
+	r := config.FreshnessResult{Project: "pj", Dataset: "ds", ConfigTable: "t", Status: config.StatusFresh, Severity: config.SeverityCritical}
+	stale := r
+	stale.Status = config.StatusStale
+	stale.Violations = []config.Violation{{Rule: config.RuleWarningDurationThreshold, Severity: config.SeverityWarning}}
+
+	for _, r := range []config.FreshnessResult{r, stale} {
+		gauges := statsDGauges(config.Report{Results: []config.FreshnessResult{r}})
+		assert.Contains(t, gauges[0].tags, Label{Name: "severity", Value: "critical"})
+	}
+}
+
+func TestTableFamilies_SeverityLabel(t *testing.T) {
+	// the severity label of a missing table is the configured one even in the grace period,
+	// so that the series don't change when the violation escalates
+	r := config.FreshnessResult{Project: "pj", Dataset: "ds", ConfigTable: "t", TableID: "t", Status: config.StatusMissing, Severity: config.SeverityCritical}
+	r.Violations = []config.Violation{{Rule: config.RuleExists, Severity: config.SeverityWarning}}
+
+	stale := TableFamilies(config.Report{Results: []config.FreshnessResult{r}})[1]
+	assert.Equal(t, "tblmonit_table_stale", stale.Name)
+	assert.Contains(t, stale.Samples[0].Labels, Label{Name: "severity", Value: "critical"})
+}
+
+func TestOTLPMetrics(t *testing.T) {
+	families := []Family{
+		{Name: "g", Help: "gauge", Type: Gauge, Samples: []Sample{{Labels: []Label{{Name: "table", Value: "t"}}, Value: 1}}},
+		{Name: "c", Type: Counter, Samples: []Sample{{Value: 3}}},
+		{Name: "empty", Type: Gauge},
+	}
+	b, err := json.Marshal(OTLPMetrics(families, time.Unix(1, 0)))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"name":"g","description":"gauge","gauge":{"dataPoints":[
+			{"attributes":[{"key":"table","value":{"stringValue":"t"}}],"timeUnixNano":"1000000000","asDouble":1}]}},
+		{"name":"c","sum":{"dataPoints":[{"timeUnixNano":"1000000000","asDouble":3}],"aggregationTemporality":2,"isMonotonic":true}}
+	]`, string(b))
+}
+
+func TestCloudMonitoring_Write(t *testing.T) {
+	var mu sync.Mutex
+	descriptors := map[string]bool{cloudMonitoringStale.metricType(): true}
+	var gets, creates int
+	var series []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v3/projects/monitoring/metricDescriptors/"):
+			gets++
+			if !descriptors[strings.TrimPrefix(r.URL.Path, "/v3/projects/monitoring/metricDescriptors/")] {
+				http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v3/projects/monitoring/metricDescriptors":
+			creates++
+			var d map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&d))
+			assert.Equal(t, "GAUGE", d["metricKind"])
+			assert.Len(t, d["labels"], 3)
+			descriptors[d["type"].(string)] = true
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v3/projects/monitoring/timeSeries":
+			var req struct{ TimeSeries []map[string]interface{} }
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			series = append(series, req.TimeSeries...)
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cm, err := NewCloudMonitoring(ctx, "monitoring", option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	assert.NoError(t, err)
+	assert.NoError(t, cm.Write(ctx, sampleReport))
+	assert.NoError(t, cm.Write(ctx, sampleReport))
+
+	assert.Equal(t, 3, gets, "descriptors are looked up only on first use")
+	assert.Equal(t, 2, creates)
+	assert.Len(t, series, 2*len(cloudMonitoringTimeSeries(sampleReport)))
+	assert.Equal(t, map[string]interface{}{
+		"type":   "custom.googleapis.com/tblmonit/table/stale",
+		"labels": map[string]interface{}{"project": "pj", "dataset": "ds", "table": "sharded_"},
+	}, series[0]["metric"])
+	point := series[0]["points"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"int64Value": "1"}, point["value"])
+	assert.Equal(t, map[string]interface{}{"endTime": "2020-01-02T09:00:00Z"}, point["interval"])
+}
+
+func TestCloudMonitoringTimeSeries_Duplicated(t *testing.T) {
+	report := config.Report{CheckedAt: sampleReport.CheckedAt}
+	report.Results = append(report.Results, sampleReport.Results...)
+	report.Results = append(report.Results, sampleReport.Results...)
+	assert.Equal(t, cloudMonitoringTimeSeries(sampleReport), cloudMonitoringTimeSeries(report))
+}
+
+func TestTableFamilies_Duplicated(t *testing.T) {
+	// table configs of the same prefix with different DateForShards have the same key
+	report := config.Report{CheckedAt: sampleReport.CheckedAt}
+	report.Results = append(report.Results, sampleReport.Results...)
+	other := sampleReport.Results[0]
+	other.TableID = "sharded_20200102"
+	other.Violations = nil
+	report.Results = append(report.Results, other)
+
+	assert.Equal(t, TableFamilies(sampleReport), TableFamilies(report))
+	assert.Equal(t, statsDGauges(sampleReport), statsDGauges(report))
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/hirosassa/tblmonit/telemetry"
+)
+
+// metrics in OTLP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"` // 2: cumulative
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []telemetry.KeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string               `json:"timeUnixNano"`
+	AsDouble     float64              `json:"asDouble"`
+}
+
+// OTLPMetrics returns the families as metrics in OTLP JSON encoding observed at t.
+// Gauges are converted into gauges, and counters into cumulative monotonic sums.
+// Families without samples are omitted.
+func OTLPMetrics(families []Family, t time.Time) []interface{} {
+	ms := make([]interface{}, 0, len(families))
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+
+		points := make([]otlpDataPoint, 0, len(f.Samples))
+		for _, s := range f.Samples {
+			attrs := make([]telemetry.Attribute, 0, len(s.Labels))
+			for _, l := range s.Labels {
+				attrs = append(attrs, telemetry.String(l.Name, l.Value))
+			}
+			points = append(points, otlpDataPoint{Attributes: telemetry.KeyValues(attrs), TimeUnixNano: telemetry.UnixNano(t), AsDouble: s.Value})
+		}
+
+		m := otlpMetric{Name: f.Name, Description: f.Help}
+		if f.Type == Counter {
+			m.Sum = &otlpSum{DataPoints: points, AggregationTemporality: 2, IsMonotonic: true}
+		} else {
+			m.Gauge = &otlpGauge{DataPoints: points}
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// ExportOTLP sends the families observed at t to OTLP/HTTP receiver.
+func ExportOTLP(ctx context.Context, e *telemetry.Exporter, families []Family, t time.Time) error {
+	return e.ExportMetrics(ctx, OTLPMetrics(families, t))
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultStatsDPrefix = "tblmonit."
+
+	// maxStatsDPacketSize is a size of UDP payload which is not fragmented on typical networks.
+	maxStatsDPacketSize = 1432
+)
+
+// StatsDConfig is a configuration of StatsD sink on the settings file.
+type StatsDConfig struct {
+	Address string   // host:port of StatsD or DogStatsD agent, metrics are not sent if empty
+	Prefix  string   // prefix of metric names (default: "tblmonit.")
+	Tags    []string // tags added to all metrics in the form of "key:value", for dogstatsd format
+	Format  string   // "dogstatsd" (default) or "statsd", which has no tags and embeds the table into metric names
+}
+
+// statsDGauge is a gauge of a table.
+type statsDGauge struct {
+	name  string
+	tags  []Label
+	value float64
+}
+
+// statsDGauges returns gauges of each table config in the report, see tableResults.
+func statsDGauges(report config.Report) []statsDGauge {
+	gauges := make([]statsDGauge, 0)
+	for _, r := range tableResults(report) {
+		tags := []Label{
+			{Name: "project", Value: r.Project},
+			{Name: "dataset", Value: r.Dataset},
+			{Name: "table", Value: r.ConfigTable},
+		}
+		if r.Owner != "" {
+			tags = append(tags, Label{Name: "owner", Value: r.Owner})
+		}
+		tags = append(tags, Label{Name: "severity", Value: string(r.Severity)})
+
+		stale := 0.0
+		if len(r.Violations) > 0 {
+			stale = 1
+		}
+		gauges = append(gauges, statsDGauge{name: "table.stale", tags: tags, value: stale})
+		if !r.LastModifiedTime.IsZero() {
+			gauges = append(gauges,
+				statsDGauge{name: "table.age_seconds", tags: tags, value: report.CheckedAt.Sub(r.LastModifiedTime).Seconds()},
+				statsDGauge{name: "table.num_rows", tags: tags, value: float64(r.NumRows)},
+			)
+		}
+	}
+	return gauges
+}
+
+var (
+	statsDTagEscaper  = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+	statsDNameEscaper = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "\n", "_")
+)
+
+// line returns the gauge in the format of StatsD.
+func (g statsDGauge) line(c StatsDConfig) string {
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = defaultStatsDPrefix
+	}
+
+	if c.Format == "statsd" {
+		// prefix.project.dataset.table.metric
+		segments := make([]string, 0, 3)
+		for _, t := range g.tags[:3] {
+			segments = append(segments, statsDNameEscaper.Replace(t.Value))
+		}
+		return fmt.Sprintf("%s%s.%s:%s|g", prefix, strings.Join(segments, "."), strings.TrimPrefix(g.name, "table."), formatValue(g.value))
+	}
+
+	tags := append([]string{}, c.Tags...)
+	for _, t := range g.tags {
+		tags = append(tags, t.Name+":"+statsDTagEscaper.Replace(t.Value))
+	}
+	return fmt.Sprintf("%s%s:%s|g|#%s", prefix, g.name, formatValue(g.value), strings.Join(tags, ","))
+}
+
+// SendStatsD sends gauges of tables on the report to StatsD agent over UDP.
+func SendStatsD(c StatsDConfig, report config.Report) error {
+	if c.Format != "" && c.Format != "dogstatsd" && c.Format != "statsd" {
+		return xerrors.Errorf("unknown format of statsd: %s", c.Format)
+	}
+
+	conn, err := net.Dial("udp", c.Address)
+	if err != nil {
+		return xerrors.Errorf("failed to connect to statsd %s: %w", c.Address, err)
+	}
+	defer conn.Close()
+
+	// lines are packed into packets as many as possible
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+	for _, g := range statsDGauges(report) {
+		line := g.line(c)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsDPacketSize {
+			if err := flush(); err != nil {
+				return xerrors.Errorf("failed to send metrics to statsd: %w", err)
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if err := flush(); err != nil {
+		return xerrors.Errorf("failed to send metrics to statsd: %w", err)
+	}
+	return nil
+}
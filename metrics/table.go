@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"github.com/hirosassa/tblmonit/config"
+)
+
+// tableLabels returns labels identifying the table config of the result.
+// Sharded tables are identified by the prefix, so that their series continue across shards.
+func tableLabels(r config.FreshnessResult) []Label {
+	return []Label{
+		{Name: "project", Value: r.Project},
+		{Name: "dataset", Value: r.Dataset},
+		{Name: "table", Value: r.ConfigTable},
+		{Name: "owner", Value: r.Owner},
+	}
+}
+
+func withLabels(labels []Label, extra ...Label) []Label {
+	ls := make([]Label, 0, len(labels)+len(extra))
+	ls = append(ls, labels...)
+	return append(ls, extra...)
+}
+
+// tableResults returns results of the report which have metrics.
+// Tables which failed to be checked are excluded because their status is unknown,
+// and only the first result of each table config is included because their series have the same labels,
+// e.g. table configs of the same prefix with different DateForShards.
+func tableResults(report config.Report) []config.FreshnessResult {
+	results := make([]config.FreshnessResult, 0, len(report.Results))
+	seen := make(map[string]bool)
+	for _, r := range report.Results {
+		if r.Status == config.StatusError || seen[r.Key()] {
+			continue
+		}
+		seen[r.Key()] = true
+		results = append(results, r)
+	}
+	return results
+}
+
+// rules returns the rules checked for the table.
+func rules(r config.FreshnessResult) []string {
+	rs := []string{config.RuleExists}
+	if r.TimeThreshold != nil {
+		rs = append(rs, config.RuleTimeThreshold)
+	}
+	if r.DurationThreshold != nil {
+		rs = append(rs, config.RuleDurationThreshold)
+	}
+	if r.WarningTimeThreshold != nil {
+		rs = append(rs, config.RuleWarningTimeThreshold)
+	}
+	if r.WarningDurationThreshold != nil {
+		rs = append(rs, config.RuleWarningDurationThreshold)
+	}
+	return rs
+}
+
+// TableFamilies returns metrics of each table config in the report, see tableResults.
+func TableFamilies(report config.Report) []Family {
+	lastModified := Family{
+		Name: "tblmonit_table_last_modified_timestamp_seconds",
+		Help: "Last modified time of the table in unix time.",
+		Type: Gauge,
+	}
+	stale := Family{
+		Name: "tblmonit_table_stale",
+		Help: "Whether the table violates the rule (1) or not (0).",
+		Type: Gauge,
+	}
+	numRows := Family{
+		Name: "tblmonit_table_num_rows",
+		Help: "Number of rows of the table.",
+		Type: Gauge,
+	}
+	timeThreshold := Family{
+		Name: "tblmonit_table_time_threshold_timestamp_seconds",
+		Help: "Time of day by which the table should be created today in unix time.",
+		Type: Gauge,
+	}
+	durationThreshold := Family{
+		Name: "tblmonit_table_duration_threshold_seconds",
+		Help: "Duration in which the table should be modified.",
+		Type: Gauge,
+	}
+
+	for _, r := range tableResults(report) {
+		labels := tableLabels(r)
+
+		if !r.LastModifiedTime.IsZero() {
+			lastModified.Samples = append(lastModified.Samples, Sample{Labels: labels, Value: unixSeconds(r.LastModifiedTime)})
+			numRows.Samples = append(numRows.Samples, Sample{Labels: labels, Value: float64(r.NumRows)})
+		}
+
+		violated := make(map[string]bool, len(r.Violations))
+		for _, v := range r.Violations {
+			violated[v.Rule] = true
+		}
+		for _, rule := range rules(r) {
+			value := 0.0
+			if violated[rule] {
+				value = 1
+			}
+			stale.Samples = append(stale.Samples, Sample{
+				Labels: withLabels(labels, Label{Name: "rule", Value: rule}, Label{Name: "severity", Value: string(r.Severity)}),
+				Value:  value,
+			})
+		}
+
+		if r.TimeThreshold != nil {
+			timeThreshold.Samples = append(timeThreshold.Samples, Sample{
+				Labels: withLabels(labels, Label{Name: "rule", Value: config.RuleTimeThreshold}),
+				Value:  unixSeconds(r.TimeThreshold.Time),
+			})
+		}
+		if r.WarningTimeThreshold != nil {
+			timeThreshold.Samples = append(timeThreshold.Samples, Sample{
+				Labels: withLabels(labels, Label{Name: "rule", Value: config.RuleWarningTimeThreshold}),
+				Value:  unixSeconds(r.WarningTimeThreshold.Time),
+			})
+		}
+		if r.DurationThreshold != nil {
+			durationThreshold.Samples = append(durationThreshold.Samples, Sample{
+				Labels: withLabels(labels, Label{Name: "rule", Value: config.RuleDurationThreshold}),
+				Value:  r.DurationThreshold.Seconds(),
+			})
+		}
+		if r.WarningDurationThreshold != nil {
+			durationThreshold.Samples = append(durationThreshold.Samples, Sample{
+				Labels: withLabels(labels, Label{Name: "rule", Value: config.RuleWarningDurationThreshold}),
+				Value:  r.WarningDurationThreshold.Seconds(),
+			})
+		}
+	}
+
+	return []Family{lastModified, stale, numRows, timeThreshold, durationThreshold}
+}
@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+const (
+	alertName             = "TableNotFresh"
+	defaultResolveTimeout = time.Hour
+)
+
+type alertmanager struct {
+	url            string
+	headers        map[string]string
+	resolveTimeout time.Duration
+	tmpl           *template.Template
+	poster         *poster
+}
+
+func newAlertmanager(c Config) (*alertmanager, error) {
+	if c.URL == "" {
+		return nil, xerrors.New("url is required for alertmanager notifier")
+	}
+
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &alertmanager{
+		url:            strings.TrimSuffix(c.URL, "/") + "/api/v2/alerts",
+		headers:        map[string]string{"Content-Type": "application/json"},
+		resolveTimeout: c.ResolveTimeout,
+		tmpl:           tmpl,
+		poster:         newPoster(c),
+	}
+	if a.resolveTimeout == 0 {
+		a.resolveTimeout = defaultResolveTimeout
+	}
+	for k, v := range c.Headers {
+		a.headers[k] = os.ExpandEnv(v)
+	}
+	return a, nil
+}
+
+// Alertmanager v2 API alert, see https://github.com/prometheus/alertmanager/blob/main/api/v2/openapi.yaml
+type postableAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt,omitempty"`
+	EndsAt       time.Time         `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// alertmanagerData is data passed to the template of alertmanager notifier.
+type alertmanagerData struct {
+	config.Report
+	Alerts []postableAlert
+}
+
+// Notify posts firing alerts for violated rules and resolved alerts for the others.
+// Tables which failed to be checked are skipped because their status is unknown.
+func (a *alertmanager) Notify(ctx context.Context, report config.Report) error {
+	alerts := a.alerts(report)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	var body []byte
+	var err error
+	if a.tmpl != nil {
+		body, err = render(a.tmpl, alertmanagerData{Report: report, Alerts: alerts})
+	} else {
+		body, err = json.Marshal(alerts)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to encode alerts: %w", err)
+	}
+	return a.poster.post(ctx, a.url, a.headers, body)
+}
+
+func (a *alertmanager) alerts(report config.Report) []postableAlert {
+	alerts := make([]postableAlert, 0)
+	for _, r := range report.Results {
+		if r.Status == config.StatusError {
+			continue
+		}
+
+		violated := make(map[string]config.Violation, len(r.Violations))
+		for _, v := range r.Violations {
+			violated[v.Rule] = v
+		}
+
+		for _, rule := range config.Rules {
+			alert := postableAlert{
+				Labels:       alertLabels(r),
+				StartsAt:     report.CheckedAt,
+				EndsAt:       report.CheckedAt,
+				GeneratorURL: r.ConsoleURL(),
+			}
+			alert.Labels["rule"] = rule
+			alert.Labels["severity"] = string(r.Severity)
+			if r.Owner != "" {
+				alert.Labels["owner"] = r.Owner
+			}
+			if v, ok := violated[rule]; ok {
+				alert.Annotations = map[string]string{
+					"summary":     fmt.Sprintf("%s is not fresh", r.FullTableID()),
+					"description": v.Reason,
+				}
+				if r.Runbook != "" {
+					alert.Annotations["runbook_url"] = r.Runbook
+				}
+				alert.EndsAt = report.CheckedAt.Add(a.resolveTimeout)
+			}
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// alertLabels returns labels identifying the table, or the group for a result of a group.
+func alertLabels(r config.FreshnessResult) map[string]string {
+	if r.IsGroup() {
+		return map[string]string{
+			"alertname": alertName,
+			"group":     r.FullTableID(),
+		}
+	}
+	return map[string]string{
+		"alertname": alertName,
+		"project":   r.Project,
+		"dataset":   r.Dataset,
+		"table":     r.TableID,
+	}
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertmanager_Notify(t *testing.T) {
+	var alerts []postableAlert
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &alerts))
+	}))
+	defer srv.Close()
+
+	report := config.Report{
+		CheckedAt: time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC),
+		Results: []config.FreshnessResult{
+			{Project: "pj", Dataset: "ds", TableID: "fresh", Status: config.StatusFresh, Severity: config.SeverityCritical},
+			{
+				Project:    "pj",
+				Dataset:    "ds",
+				TableID:    "stale",
+				Status:     config.StatusStale,
+				Severity:   config.SeverityWarning,
+				Violations: []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityWarning}},
+			},
+			{Project: "pj", Dataset: "ds", TableID: "error", Status: config.StatusError},
+		},
+	}
+
+	n, err := New(Config{Type: "alertmanager", URL: srv.URL + "/", ResolveTimeout: 30 * time.Minute})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), report))
+
+	assert.Equal(t, "/api/v2/alerts", path)
+	assert.Equal(t, 2*len(config.Rules), len(alerts), "alerts for error tables are not sent")
+
+	firing := 0
+	for _, a := range alerts {
+		if a.Labels["rule"] == config.RuleDurationThreshold && a.Labels["table"] == "stale" {
+			firing++
+			assert.Equal(t, "warning", a.Labels["severity"])
+			assert.Equal(t, "not modified", a.Annotations["description"])
+			assert.Equal(t, report.CheckedAt.Add(30*time.Minute), a.EndsAt)
+			continue
+		}
+		assert.Equal(t, report.CheckedAt, a.EndsAt, "alerts for fresh rules are resolved")
+	}
+	assert.Equal(t, 1, firing)
+}
+
+func TestAlertmanager_NotifyGroup(t *testing.T) {
+	var alerts []postableAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &alerts))
+	}))
+	defer srv.Close()
+
+	report := config.Report{
+		CheckedAt: time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC),
+		Results: []config.FreshnessResult{{
+			Status:     config.StatusStale,
+			Group:      "owner",
+			Ownership:  config.Ownership{Owner: "data-platform"},
+			Violations: []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "2 of 3 tables are not fresh", Severity: config.SeverityCritical}},
+		}},
+	}
+
+	n, err := New(Config{Type: "alertmanager", URL: srv.URL})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), report))
+
+	assert.Equal(t, len(config.Rules), len(alerts))
+	for _, a := range alerts {
+		assert.Equal(t, "owner:data-platform", a.Labels["group"])
+		assert.NotContains(t, a.Labels, "table")
+		assert.Equal(t, "", a.GeneratorURL)
+	}
+}
+
+func TestAlertmanager_NotifySeverityLabel(t *testing.T) {
+	var alerts []postableAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &alerts))
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "alertmanager", URL: srv.URL})
+	assert.NoError(t, err)
+
+	// a missing table in the grace period fires as warning, and is resolved with the same labels
+	missing := config.FreshnessResult{
+		Project:     "pj",
+		Dataset:     "ds",
+		ConfigTable: "table",
+		TableID:     "table",
+		Status:      config.StatusMissing,
+		Severity:    config.SeverityCritical,
+		Violations:  []config.Violation{{Rule: config.RuleExists, Reason: "Table doesn't exist", Severity: config.SeverityWarning}},
+	}
+	fresh := missing
+	fresh.Status, fresh.Violations = config.StatusFresh, nil
+
+	var labels []map[string]string
+	for _, r := range []config.FreshnessResult{missing, fresh} {
+		assert.NoError(t, n.Notify(context.Background(), config.Report{Results: []config.FreshnessResult{r}}))
+		for _, a := range alerts {
+			if a.Labels["rule"] == config.RuleExists {
+				labels = append(labels, a.Labels)
+			}
+		}
+	}
+	assert.Equal(t, 2, len(labels))
+	assert.Equal(t, labels[0], labels[1])
+	assert.Equal(t, "critical", labels[0]["severity"])
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+// BatchStore persists deferred notifications of each notifier across runs.
+type BatchStore interface {
+	LoadBatch(name string) ([]byte, error)
+	SaveBatch(name string, v []byte) error
+}
+
+// QuietHours is a time range of a day on local time zone, which may span midnight.
+type QuietHours struct {
+	Start string // in "15:04" format
+	End   string // in "15:04" format
+}
+
+// contains returns true if t is in the quiet hours.
+func (q *QuietHours) contains(t time.Time) (bool, error) {
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return false, xerrors.Errorf("invalid start of quiet hours: %w", err)
+	}
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return false, xerrors.Errorf("invalid end of quiet hours: %w", err)
+	}
+
+	t = t.In(time.Local)
+	minutes := t.Hour()*60 + t.Minute()
+	s, e := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if s <= e {
+		return s <= minutes && minutes < e, nil
+	}
+	return minutes >= s || minutes < e, nil
+}
+
+// batch is deferred notifications of a notifier.
+type batch struct {
+	Since     time.Time                         `json:"since"`
+	Results   map[string]config.FreshnessResult `json:"results"`
+	Recovered map[string]config.FreshnessResult `json:"recovered"`
+}
+
+// batches returns true if the notifier defers notifications.
+// Notifiers which deduplicate alerts by themselves don't defer notifications.
+func (c *Config) batches() bool {
+	return !c.deduplicates() && (c.DigestWindow > 0 || c.QuietHours != nil)
+}
+
+// batch adds the report to the deferred notifications, and returns a report which should be sent now
+// with a function to save the deferred notifications. delivered should be true if the report is sent,
+// otherwise the notifications in the report are kept deferred and sent on the next run.
+// All notifications are sent as a digest when the digest window has passed since the first deferred one,
+// but only critical ones are sent during quiet hours.
+func (c *Config) batch(store BatchStore, report config.Report) (digest config.Report, commit func(delivered bool) error, err error) {
+	b, err := c.loadBatch(store)
+	if err != nil {
+		return config.Report{}, nil, err
+	}
+
+	if b.Since.IsZero() && (len(report.Results) > 0 || len(report.Recovered) > 0) {
+		b.Since = report.CheckedAt
+	}
+	// keep only the latest result of each table
+	for _, r := range report.Results {
+		b.Results[r.Key()] = r
+		delete(b.Recovered, r.Key())
+	}
+	for _, r := range report.Recovered {
+		b.Recovered[r.Key()] = r
+		delete(b.Results, r.Key())
+	}
+	undelivered, err := json.Marshal(b)
+	if err != nil {
+		return config.Report{}, nil, xerrors.Errorf("failed to encode batch: %w", err)
+	}
+
+	due := report.CheckedAt.Sub(b.Since) >= c.DigestWindow
+	quiet := false
+	if c.QuietHours != nil {
+		quiet, err = c.QuietHours.contains(report.CheckedAt)
+		if err != nil {
+			return config.Report{}, nil, err
+		}
+	}
+
+	digest = config.Report{CheckedAt: report.CheckedAt, Results: make([]config.FreshnessResult, 0), GroupBy: c.GroupBy, Totals: report.Totals}
+	if due {
+		for k, r := range b.Results {
+			if quiet && r.HighestSeverity() != config.SeverityCritical {
+				continue
+			}
+			digest.Results = append(digest.Results, r)
+			delete(b.Results, k)
+		}
+		if !quiet {
+			for k, r := range b.Recovered {
+				digest.Recovered = append(digest.Recovered, r)
+				delete(b.Recovered, k)
+			}
+		}
+	}
+	if len(b.Results) == 0 && len(b.Recovered) == 0 {
+		b.Since = time.Time{}
+	}
+	config.SortResults(digest.Results)
+	config.SortResults(digest.Recovered)
+
+	commit = func(delivered bool) error {
+		if !delivered {
+			return store.SaveBatch(c.Name, undelivered)
+		}
+		return c.saveBatch(store, b)
+	}
+	return digest, commit, nil
+}
+
+func (c *Config) loadBatch(store BatchStore) (batch, error) {
+	var b batch
+	v, err := store.LoadBatch(c.Name)
+	if err != nil {
+		return batch{}, err
+	}
+	if v != nil {
+		if err := json.Unmarshal(v, &b); err != nil {
+			return batch{}, xerrors.Errorf("failed to decode batch: %w", err)
+		}
+	}
+	if b.Results == nil {
+		b.Results = make(map[string]config.FreshnessResult)
+	}
+	if b.Recovered == nil {
+		b.Recovered = make(map[string]config.FreshnessResult)
+	}
+	return b, nil
+}
+
+func (c *Config) saveBatch(store BatchStore, b batch) error {
+	v, err := json.Marshal(b)
+	if err != nil {
+		return xerrors.Errorf("failed to encode batch: %w", err)
+	}
+	return store.SaveBatch(c.Name, v)
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryBatchStore map[string][]byte
+
+func (m memoryBatchStore) LoadBatch(name string) ([]byte, error) {
+	return m[name], nil
+}
+
+func (m memoryBatchStore) SaveBatch(name string, v []byte) error {
+	m[name] = v
+	return nil
+}
+
+func TestQuietHours_Contains(t *testing.T) {
+	tests := []struct {
+		q       QuietHours
+		t       time.Time
+		wantRes bool
+	}{
+		{q: QuietHours{Start: "22:00", End: "08:00"}, t: time.Date(2020, 1, 1, 23, 0, 0, 0, time.Local), wantRes: true},
+		{q: QuietHours{Start: "22:00", End: "08:00"}, t: time.Date(2020, 1, 1, 7, 59, 0, 0, time.Local), wantRes: true},
+		{q: QuietHours{Start: "22:00", End: "08:00"}, t: time.Date(2020, 1, 1, 8, 0, 0, 0, time.Local), wantRes: false},
+		{q: QuietHours{Start: "12:00", End: "13:00"}, t: time.Date(2020, 1, 1, 12, 30, 0, 0, time.Local), wantRes: true},
+		{q: QuietHours{Start: "12:00", End: "13:00"}, t: time.Date(2020, 1, 1, 13, 30, 0, 0, time.Local), wantRes: false},
+	}
+	for _, tt := range tests {
+		actual, err := tt.q.contains(tt.t)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.wantRes, actual, tt.t)
+	}
+}
+
+func stale(dataset, table string, severity config.Severity) config.FreshnessResult {
+	return config.FreshnessResult{
+		Project:     "pj",
+		Dataset:     dataset,
+		ConfigTable: table,
+		TableID:     table,
+		Status:      config.StatusStale,
+		Violations:  []config.Violation{{Rule: config.RuleDurationThreshold, Severity: severity}},
+	}
+}
+
+func TestConfig_BatchDigest(t *testing.T) {
+	store := memoryBatchStore{}
+	c := Config{Name: "digest", DigestWindow: 30 * time.Minute, GroupBy: "dataset"}
+	base := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+
+	steps := []struct {
+		results []config.FreshnessResult
+		sent    []string
+	}{
+		{results: []config.FreshnessResult{stale("ds1", "a", config.SeverityCritical)}, sent: []string{}},
+		{results: []config.FreshnessResult{stale("ds1", "b", config.SeverityWarning), stale("ds1", "a", config.SeverityCritical)}, sent: []string{}},
+		{results: []config.FreshnessResult{stale("ds2", "c", config.SeverityWarning)}, sent: []string{"pj.ds1.a", "pj.ds1.b", "pj.ds2.c"}},
+		{results: []config.FreshnessResult{}, sent: []string{}},
+	}
+	for i, st := range steps {
+		report := config.Report{CheckedAt: base.Add(time.Duration(i) * 15 * time.Minute), Results: st.results}
+		digest, commit, err := c.batch(store, report)
+		assert.NoError(t, err)
+		assert.NoError(t, commit(true))
+		assert.Equal(t, st.sent, tableIDs(digest.Results), "step %d", i)
+		assert.Equal(t, "dataset", digest.GroupBy)
+	}
+}
+
+func TestConfig_BatchQuietHours(t *testing.T) {
+	store := memoryBatchStore{}
+	c := Config{Name: "quiet", QuietHours: &QuietHours{Start: "22:00", End: "08:00"}}
+
+	report := config.Report{
+		CheckedAt: time.Date(2020, 1, 1, 23, 0, 0, 0, time.Local),
+		Results:   []config.FreshnessResult{stale("ds1", "a", config.SeverityCritical), stale("ds1", "b", config.SeverityWarning)},
+	}
+	sent, commit, err := c.batch(store, report)
+	assert.NoError(t, err)
+	assert.NoError(t, commit(true))
+	assert.Equal(t, []string{"pj.ds1.a"}, tableIDs(sent.Results), "only critical tables are sent during quiet hours")
+
+	report = config.Report{CheckedAt: time.Date(2020, 1, 2, 8, 0, 0, 0, time.Local), Results: []config.FreshnessResult{}}
+	sent, commit, err = c.batch(store, report)
+	assert.NoError(t, err)
+	assert.NoError(t, commit(true))
+	assert.Equal(t, []string{"pj.ds1.b"}, tableIDs(sent.Results), "deferred tables are sent in the morning")
+}
+
+func TestConfig_BatchUndelivered(t *testing.T) {
+	store := memoryBatchStore{}
+	c := Config{Name: "digest", DigestWindow: 30 * time.Minute}
+	base := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+
+	report := config.Report{CheckedAt: base, Results: []config.FreshnessResult{stale("ds1", "a", config.SeverityCritical)}}
+	_, commit, err := c.batch(store, report)
+	assert.NoError(t, err)
+	assert.NoError(t, commit(true))
+
+	report = config.Report{CheckedAt: base.Add(30 * time.Minute), Results: []config.FreshnessResult{}}
+	digest, commit, err := c.batch(store, report)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pj.ds1.a"}, tableIDs(digest.Results))
+	assert.NoError(t, commit(false))
+
+	report.CheckedAt = base.Add(45 * time.Minute)
+	digest, commit, err = c.batch(store, report)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pj.ds1.a"}, tableIDs(digest.Results), "the digest failed to be sent is sent again")
+	assert.NoError(t, commit(true))
+
+	report.CheckedAt = base.Add(time.Hour)
+	digest, _, err = c.batch(store, report)
+	assert.NoError(t, err)
+	assert.Empty(t, digest.Results)
+}
+
+func tableIDs(results []config.FreshnessResult) []string {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.FullTableID())
+	}
+	return ids
+}
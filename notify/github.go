@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultGitHubURL = "https://api.github.com"
+	githubPageSize   = 100
+)
+
+// github is a tracker on GitHub Issues.
+// The metadata of a ticket is embedded into the issue body as an HTML comment.
+type github struct {
+	url     string // URL of the repository API
+	headers map[string]string
+	labels  []string
+	poster  *poster
+}
+
+func newGitHub(c Config) (*ticketNotifier, error) {
+	token := os.ExpandEnv(c.APIKey)
+	if token == "" {
+		return nil, xerrors.New("apiKey is required for github notifier")
+	}
+	if strings.Count(c.Repository, "/") != 1 {
+		return nil, xerrors.Errorf("repository should be in the form of owner/repo for github notifier: %s", c.Repository)
+	}
+
+	u := c.URL
+	if u == "" {
+		u = defaultGitHubURL
+	}
+	g := &github{
+		url: fmt.Sprintf("%s/repos/%s", strings.TrimSuffix(u, "/"), c.Repository),
+		headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Accept":        "application/vnd.github+json",
+			"Authorization": "Bearer " + token,
+		},
+		labels: append([]string{ticketLabel}, c.Labels...),
+		poster: newPoster(c),
+	}
+	return newTicketNotifier(c, g)
+}
+
+// GitHub REST API issues, see https://docs.github.com/en/rest/issues
+type githubIssue struct {
+	Number      int             `json:"number"`
+	Body        string          `json:"body"`
+	PullRequest json.RawMessage `json:"pull_request,omitempty"`
+}
+
+func (g *github) openTickets(ctx context.Context) (map[string]ticket, error) {
+	tickets := make(map[string]ticket)
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("%s/issues?state=open&labels=%s&per_page=%d&page=%d", g.url, ticketLabel, githubPageSize, page)
+		resp, err := g.poster.do(ctx, http.MethodGet, u, g.headers, nil)
+		if err != nil {
+			return nil, err
+		}
+		var issues []githubIssue
+		if err := json.Unmarshal(resp, &issues); err != nil {
+			return nil, xerrors.Errorf("failed to decode issues: %w", err)
+		}
+
+		for _, i := range issues {
+			if i.PullRequest != nil {
+				continue
+			}
+			if m, ok := parseTicketMeta(i.Body); ok {
+				tickets[m.Key] = ticket{ID: fmt.Sprint(i.Number), Body: i.Body, ticketMeta: m}
+			}
+		}
+		if len(issues) < githubPageSize {
+			return tickets, nil
+		}
+	}
+}
+
+func (g *github) open(ctx context.Context, title, body string, m ticketMeta) error {
+	return g.send(ctx, http.MethodPost, g.url+"/issues", map[string]interface{}{
+		"title":  title,
+		"body":   body + "\n\n" + m.marker(),
+		"labels": g.labels,
+	})
+}
+
+func (g *github) comment(ctx context.Context, t ticket, body string, m ticketMeta) error {
+	if err := g.send(ctx, http.MethodPost, g.url+"/issues/"+t.ID+"/comments", map[string]interface{}{"body": body}); err != nil {
+		return err
+	}
+	return g.send(ctx, http.MethodPatch, g.url+"/issues/"+t.ID, map[string]interface{}{
+		"body": ticketMarker.ReplaceAllLiteralString(t.Body, m.marker()),
+	})
+}
+
+func (g *github) close(ctx context.Context, t ticket, body string) error {
+	if err := g.send(ctx, http.MethodPost, g.url+"/issues/"+t.ID+"/comments", map[string]interface{}{"body": body}); err != nil {
+		return err
+	}
+	return g.send(ctx, http.MethodPatch, g.url+"/issues/"+t.ID, map[string]interface{}{
+		"state":        "closed",
+		"state_reason": "completed",
+	})
+}
+
+func (g *github) send(ctx context.Context, method, u string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return xerrors.Errorf("failed to encode request: %w", err)
+	}
+	// POST requests create issues, comments and transitions, so they must not be applied twice by retries
+	if method == http.MethodPost {
+		_, err = g.poster.doOnce(ctx, method, u, g.headers, body)
+		return err
+	}
+	_, err = g.poster.do(ctx, method, u, g.headers, body)
+	return err
+}
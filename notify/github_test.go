@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitHub is an in-memory repository of GitHub Issues.
+type fakeGitHub struct {
+	issues   map[int]map[string]interface{}
+	comments map[int][]string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	body, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+
+	var n int
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/org/repo/issues":
+		issues := make([]map[string]interface{}, 0)
+		for _, i := range f.issues {
+			if i["state"] == "open" && r.URL.Query().Get("labels") == "tblmonit" {
+				issues = append(issues, i)
+			}
+		}
+		json.NewEncoder(w).Encode(issues)
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/org/repo/issues":
+		n = len(f.issues) + 1
+		req["number"], req["state"] = n, "open"
+		f.issues[n] = req
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost:
+		fmt.Sscanf(r.URL.Path, "/repos/org/repo/issues/%d/comments", &n)
+		f.comments[n] = append(f.comments[n], req["body"].(string))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPatch:
+		fmt.Sscanf(r.URL.Path, "/repos/org/repo/issues/%d", &n)
+		for k, v := range req {
+			f.issues[n][k] = v
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitHub_Notify(t *testing.T) {
+	f := &fakeGitHub{issues: make(map[int]map[string]interface{}), comments: make(map[int][]string)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	n, err := New(Config{Type: "github", URL: srv.URL, APIKey: "token", Repository: "org/repo", OpenAfter: time.Hour, Labels: []string{"data"}})
+	assert.NoError(t, err)
+
+	checkedAt := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	result := func(lastModified time.Duration, vs ...config.Violation) config.FreshnessResult {
+		r := config.FreshnessResult{
+			Project:          "pj",
+			Dataset:          "ds",
+			ConfigTable:      "table",
+			TableID:          "table",
+			Status:           config.StatusFresh,
+			LastModifiedTime: checkedAt.Add(-lastModified),
+			Violations:       vs,
+
+			WarningDurationThreshold: &config.DurationThreshold{Duration: time.Hour},
+			DurationThreshold:        &config.DurationThreshold{Duration: 2 * time.Hour},
+		}
+		if len(vs) > 0 {
+			r.Status = config.StatusStale
+		}
+		return r
+	}
+	warning := config.Violation{Rule: config.RuleWarningDurationThreshold, Reason: "not modified", Severity: config.SeverityWarning}
+	critical := config.Violation{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityCritical}
+
+	steps := []struct {
+		result   config.FreshnessResult
+		state    string
+		comments int
+	}{
+		{result: result(90*time.Minute, warning)},                               // stale for 30m
+		{result: result(150*time.Minute, warning), state: "open"},               // stale for 90m
+		{result: result(150*time.Minute, warning), state: "open"},               // not changed
+		{result: result(180*time.Minute, critical), state: "open", comments: 1}, // escalated
+		{result: result(10 * time.Minute), state: "closed", comments: 2},        // recovered
+	}
+	for i, st := range steps {
+		report := config.Report{CheckedAt: checkedAt, Results: []config.FreshnessResult{st.result}}
+		assert.NoError(t, n.Notify(context.Background(), report))
+
+		if st.state == "" {
+			assert.Equal(t, 0, len(f.issues), "step %d", i)
+			continue
+		}
+		assert.Equal(t, 1, len(f.issues), "step %d", i)
+		assert.Equal(t, st.state, f.issues[1]["state"], "step %d", i)
+		assert.Equal(t, st.comments, len(f.comments[1]), "step %d", i)
+	}
+
+	issue := f.issues[1]
+	assert.Equal(t, "[tblmonit] pj.ds.table is not fresh", issue["title"])
+	assert.Equal(t, []interface{}{"tblmonit", "data"}, issue["labels"])
+	assert.True(t, strings.HasPrefix(issue["body"].(string), "pj.ds.table is stale since 2020-01-02 07:30:00 UTC."))
+	m, ok := parseTicketMeta(issue["body"].(string))
+	assert.True(t, ok)
+	assert.Equal(t, ticketMeta{Key: "pj.ds.table", State: "stale: duration_threshold (critical)"}, m)
+	assert.Equal(t, "pj.ds.table became fresh at 2020-01-02 09:00:00 UTC.", f.comments[1][1])
+}
+
+func TestGitHub_NotifyMissingTable(t *testing.T) {
+	f := &fakeGitHub{issues: make(map[int]map[string]interface{}), comments: make(map[int][]string)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	n, err := New(Config{Type: "github", URL: srv.URL, APIKey: "token", Repository: "org/repo", OpenAfter: time.Hour})
+	assert.NoError(t, err)
+
+	// a missing table without TimeThreshold is stale since it was first observed by the alert store
+	checkedAt := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	report := config.Report{CheckedAt: checkedAt, Results: []config.FreshnessResult{{
+		Project:     "pj",
+		Dataset:     "ds",
+		ConfigTable: "table",
+		TableID:     "table",
+		Status:      config.StatusMissing,
+		Violations:  []config.Violation{{Rule: config.RuleExists, Reason: "Table doesn't exist", Severity: config.SeverityCritical}},
+		StaleSince:  checkedAt.Add(-2 * time.Hour),
+	}}}
+	assert.NoError(t, n.Notify(context.Background(), report))
+	assert.Equal(t, 1, len(f.issues))
+	assert.True(t, strings.HasPrefix(f.issues[1]["body"].(string), "pj.ds.table is missing since 2020-01-02 07:00:00 UTC."))
+}
+
+func TestGitHub_OpenIsNotRetried(t *testing.T) {
+	creates := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		creates++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "github", URL: srv.URL, APIKey: "token", Repository: "org/repo"})
+	assert.NoError(t, err)
+	report := config.Report{CheckedAt: time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC), Results: []config.FreshnessResult{{
+		Project:    "pj",
+		Dataset:    "ds",
+		TableID:    "table",
+		Status:     config.StatusStale,
+		Violations: []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityCritical}},
+	}}}
+	assert.Error(t, n.Notify(context.Background(), report))
+	assert.Equal(t, 1, creates, "the issue may have been created, so it should not be retried")
+}
+
+func TestGitHub_NotifyPartialFailure(t *testing.T) {
+	var titles []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		var req map[string]interface{}
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &req))
+		if req["title"] == "[tblmonit] pj.ds.a is not fresh" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		titles = append(titles, req["title"].(string))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	stale := func(table string) config.FreshnessResult {
+		return config.FreshnessResult{
+			Project:     "pj",
+			Dataset:     "ds",
+			ConfigTable: table,
+			TableID:     table,
+			Status:      config.StatusStale,
+			Violations:  []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityCritical}},
+		}
+	}
+	report := config.Report{CheckedAt: time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC), Results: []config.FreshnessResult{stale("a"), stale("b")}}
+
+	n, err := New(Config{Type: "github", URL: srv.URL, APIKey: "token", Repository: "org/repo"})
+	assert.NoError(t, err)
+	assert.Error(t, n.Notify(context.Background(), report))
+	assert.Equal(t, []string{"[tblmonit] pj.ds.b is not fresh"}, titles, "the other tickets are updated even if one fails")
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+type googleChat struct {
+	url    string
+	tmpl   *template.Template
+	poster *poster
+}
+
+func newGoogleChat(c Config) (*googleChat, error) {
+	if c.URL == "" {
+		return nil, xerrors.New("url is required for googlechat notifier")
+	}
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	return &googleChat{
+		url:    c.URL,
+		tmpl:   tmpl,
+		poster: newPoster(c),
+	}, nil
+}
+
+// Notify posts a card listing old tables and recovered tables to Google Chat incoming webhook.
+func (g *googleChat) Notify(ctx context.Context, report config.Report) error {
+	if len(report.Failures()) == 0 && len(report.Recovered) == 0 {
+		return nil
+	}
+
+	body, err := g.render(report)
+	if err != nil {
+		return xerrors.Errorf("failed to render message: %w", err)
+	}
+	headers := map[string]string{"Content-Type": "application/json; charset=UTF-8"}
+	return g.poster.post(ctx, g.url, headers, body)
+}
+
+// render returns the message rendered by the template, or the built-in message.
+func (g *googleChat) render(report config.Report) ([]byte, error) {
+	if g.tmpl != nil {
+		return render(g.tmpl, report)
+	}
+	return json.Marshal(newChatMessage(report))
+}
+
+// Google Chat card messages, see https://developers.google.com/chat/api/reference/rest/v1/cards
+type chatMessage struct {
+	CardsV2 []chatCardWithID `json:"cardsV2"`
+}
+
+type chatCardWithID struct {
+	CardID string   `json:"cardId"`
+	Card   chatCard `json:"card"`
+}
+
+type chatCard struct {
+	Header   chatHeader    `json:"header"`
+	Sections []chatSection `json:"sections"`
+}
+
+type chatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type chatSection struct {
+	Header  string       `json:"header,omitempty"`
+	Widgets []chatWidget `json:"widgets"`
+}
+
+type chatWidget struct {
+	DecoratedText *chatDecoratedText `json:"decoratedText,omitempty"`
+	ButtonList    *chatButtonList    `json:"buttonList,omitempty"`
+}
+
+type chatDecoratedText struct {
+	TopLabel string `json:"topLabel,omitempty"`
+	Text     string `json:"text"`
+	WrapText bool   `json:"wrapText"`
+}
+
+type chatButtonList struct {
+	Buttons []chatButton `json:"buttons"`
+}
+
+type chatButton struct {
+	Text    string      `json:"text"`
+	OnClick chatOnClick `json:"onClick"`
+}
+
+type chatOnClick struct {
+	OpenLink chatOpenLink `json:"openLink"`
+}
+
+type chatOpenLink struct {
+	URL string `json:"url"`
+}
+
+func newChatMessage(report config.Report) chatMessage {
+	failures := report.Failures()
+	sections := make([]chatSection, 0, len(failures)+1)
+	if report.GroupBy != "" {
+		sections = append(sections, groupedChatSections(report)...)
+		failures = nil
+	}
+	for _, r := range failures {
+		label := string(r.Status)
+		if r.Owner != "" {
+			label += " / owner: " + r.Owner
+		}
+		var buttons []chatButton
+		if u := r.ConsoleURL(); u != "" {
+			buttons = append(buttons, chatButton{
+				Text:    "Open in BigQuery",
+				OnClick: chatOnClick{OpenLink: chatOpenLink{URL: u}},
+			})
+		}
+		if r.Runbook != "" {
+			buttons = append(buttons, chatButton{
+				Text:    "Runbook",
+				OnClick: chatOnClick{OpenLink: chatOpenLink{URL: r.Runbook}},
+			})
+		}
+
+		widgets := []chatWidget{{DecoratedText: &chatDecoratedText{
+			TopLabel: label,
+			Text:     strings.Join(r.Reason, "\n"),
+			WrapText: true,
+		}}}
+		if len(buttons) > 0 {
+			widgets = append(widgets, chatWidget{ButtonList: &chatButtonList{Buttons: buttons}})
+		}
+		sections = append(sections, chatSection{Header: r.FullTableID(), Widgets: widgets})
+	}
+
+	if len(report.Recovered) > 0 {
+		widgets := make([]chatWidget, 0, len(report.Recovered))
+		for _, r := range report.Recovered {
+			widgets = append(widgets, chatWidget{DecoratedText: &chatDecoratedText{
+				TopLabel: "recovered",
+				Text:     r.FullTableID(),
+				WrapText: true,
+			}})
+		}
+		sections = append(sections, chatSection{Header: "Recovered", Widgets: widgets})
+	}
+
+	title := fmt.Sprintf("%d tables are not fresh", len(report.Failures()))
+	if len(report.Failures()) == 0 {
+		title = fmt.Sprintf("%d tables recovered", len(report.Recovered))
+	}
+
+	return chatMessage{CardsV2: []chatCardWithID{{
+		CardID: "tblmonit",
+		Card: chatCard{
+			Header: chatHeader{
+				Title:    title,
+				Subtitle: "checked at " + report.CheckedAt.Format("2006-01-02 15:04:05 MST"),
+			},
+			Sections: sections,
+		},
+	}}}
+}
+
+// groupedChatSections returns a section for each group of old tables in a digest.
+func groupedChatSections(report config.Report) []chatSection {
+	groups := report.Groups()
+	sections := make([]chatSection, 0, len(groups))
+	for _, g := range groups {
+		widgets := make([]chatWidget, 0, len(g.Results))
+		for _, r := range g.Results {
+			widgets = append(widgets, chatWidget{DecoratedText: &chatDecoratedText{
+				TopLabel: fmt.Sprintf("%s (%s)", r.FullTableID(), r.Status),
+				Text:     strings.Join(r.Reason, "\n"),
+				WrapText: true,
+			}})
+		}
+		key := g.Key
+		if key == "" {
+			key = "(none)"
+		}
+		sections = append(sections, chatSection{
+			Header:  fmt.Sprintf("%s: %s (%d tables)", report.GroupBy, key, len(g.Results)),
+			Widgets: widgets,
+		})
+	}
+	return sections
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoogleChat_Notify(t *testing.T) {
+	var msg chatMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &msg))
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "googlechat", URL: srv.URL})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), sampleReport))
+
+	card := msg.CardsV2[0].Card
+	assert.Equal(t, "1 tables are not fresh", card.Header.Title)
+	assert.Equal(t, 1, len(card.Sections))
+	assert.Equal(t, "pj.ds.stale", card.Sections[0].Header)
+	assert.Equal(t, "The table should be modified in 1h0m0s, but not modified in 1h30m0s", card.Sections[0].Widgets[0].DecoratedText.Text)
+	assert.Equal(t, "https://console.cloud.google.com/bigquery?project=pj&ws=!1m5!1m4!4m3!1spj!2sds!3sstale", card.Sections[0].Widgets[1].ButtonList.Buttons[0].OnClick.OpenLink.URL)
+}
+
+func TestGoogleChat_NotifyWithTemplate(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{
+		Type:     "googlechat",
+		URL:      srv.URL,
+		Template: `{"text": {{range .Failures}}{{json (printf "%s: %s" .FullTableID (join ", " .Reason))}}{{end}}}`,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), sampleReport))
+	assert.Equal(t, `{"text": "pj.ds.stale: The table should be modified in 1h0m0s, but not modified in 1h30m0s"}`, string(body))
+}
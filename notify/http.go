@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 3
+
+	maxResponseSize = 10 << 20
+)
+
+// retryWait is a base wait time between retries, doubled on each retry.
+var retryWait = time.Second
+
+type poster struct {
+	client  *http.Client
+	retries int
+}
+
+func newPoster(c Config) *poster {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	retries := defaultRetries
+	if c.Retries != nil {
+		retries = *c.Retries
+	}
+	return &poster{
+		client:  &http.Client{Timeout: timeout},
+		retries: retries,
+	}
+}
+
+// post sends body to url, and retries on network errors and server errors.
+func (p *poster) post(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	_, err := p.do(ctx, http.MethodPost, url, headers, body)
+	return err
+}
+
+// do sends a request and returns the response body, and retries on network errors and server errors.
+func (p *poster) do(ctx context.Context, method, url string, headers map[string]string, body []byte) ([]byte, error) {
+	return p.request(ctx, method, url, headers, body, true)
+}
+
+// doOnce is do for a non-idempotent request such as creating an issue, which may have been applied
+// on network errors and server errors. It is retried only when it is rejected by rate limiting.
+func (p *poster) doOnce(ctx context.Context, method, url string, headers map[string]string, body []byte) ([]byte, error) {
+	return p.request(ctx, method, url, headers, body, false)
+}
+
+// retryable is whether a failed request can be sent again.
+type retryable int
+
+const (
+	notRetryable          retryable = iota
+	retryableIfIdempotent           // the request may have been applied, e.g. on network errors and server errors
+	retryableAlways                 // the request was rejected without being applied, i.e. by rate limiting
+)
+
+func (p *poster) request(ctx context.Context, method, url string, headers map[string]string, body []byte, idempotent bool) ([]byte, error) {
+	var lastErr error
+	wait := retryWait
+	for i := 0; i <= p.retries; i++ {
+		if i > 0 {
+			log.Debug().Msgf("retry %s %s after %s: %v", method, url, wait, lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+
+		resp, retry, err := p.send(ctx, method, url, headers, body)
+		if err == nil {
+			return resp, nil
+		}
+		if retry == notRetryable || (retry == retryableIfIdempotent && !idempotent) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, xerrors.Errorf("gave up after %d retries: %w", p.retries, lastErr)
+}
+
+func (p *poster) send(ctx context.Context, method, url string, headers map[string]string, body []byte) (respBody []byte, retry retryable, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, notRetryable, xerrors.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, retryableIfIdempotent, xerrors.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		if err != nil {
+			return nil, retryableIfIdempotent, xerrors.Errorf("failed to read response: %w", err)
+		}
+		return respBody, notRetryable, nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retry = retryableAlways
+	case resp.StatusCode >= 500:
+		retry = retryableIfIdempotent
+	default:
+		retry = notRetryable
+	}
+	return nil, retry, xerrors.Errorf("unexpected status %s: %s", resp.Status, msg)
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultJiraIssueType = "Task"
+	jiraPageSize         = 100
+)
+
+// jira is a tracker on Jira.
+// The metadata of a ticket is stored as an issue property whose key is "tblmonit".
+type jira struct {
+	url        string // URL of the REST API
+	headers    map[string]string
+	projectKey string
+	issueType  string
+	labels     []string
+	poster     *poster
+}
+
+func newJira(c Config) (*ticketNotifier, error) {
+	token := os.ExpandEnv(c.APIKey)
+	if token == "" {
+		return nil, xerrors.New("apiKey is required for jira notifier")
+	}
+	if c.URL == "" {
+		return nil, xerrors.New("url is required for jira notifier")
+	}
+	if c.ProjectKey == "" {
+		return nil, xerrors.New("projectKey is required for jira notifier")
+	}
+
+	// Jira Cloud uses basic authentication with an API token, and Jira Data Center uses a personal access token.
+	auth := "Bearer " + token
+	if user := os.ExpandEnv(c.User); user != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+token))
+	}
+	issueType := c.IssueType
+	if issueType == "" {
+		issueType = defaultJiraIssueType
+	}
+	j := &jira{
+		url: strings.TrimSuffix(c.URL, "/") + "/rest/api/2",
+		headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Accept":        "application/json",
+			"Authorization": auth,
+		},
+		projectKey: c.ProjectKey,
+		issueType:  issueType,
+		labels:     append([]string{ticketLabel}, c.Labels...),
+		poster:     newPoster(c),
+	}
+	return newTicketNotifier(c, j)
+}
+
+// Jira REST API v2 issues, see https://developer.atlassian.com/cloud/jira/platform/rest/v2/
+type jiraSearchResult struct {
+	StartAt int         `json:"startAt"`
+	Total   int         `json:"total"`
+	Issues  []jiraIssue `json:"issues"`
+}
+
+type jiraIssue struct {
+	Key        string `json:"key"`
+	Properties struct {
+		Tblmonit *ticketMeta `json:"tblmonit"`
+	} `json:"properties"`
+}
+
+type jiraTransitions struct {
+	Transitions []struct {
+		ID string `json:"id"`
+		To struct {
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"to"`
+	} `json:"transitions"`
+}
+
+func (j *jira) openTickets(ctx context.Context) (map[string]ticket, error) {
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done`, j.projectKey, ticketLabel)
+	tickets := make(map[string]ticket)
+	for startAt := 0; ; startAt += jiraPageSize {
+		q := url.Values{}
+		q.Set("jql", jql)
+		q.Set("fields", "summary")
+		q.Set("properties", ticketLabel)
+		q.Set("startAt", fmt.Sprint(startAt))
+		q.Set("maxResults", fmt.Sprint(jiraPageSize))
+		resp, err := j.poster.do(ctx, http.MethodGet, j.url+"/search?"+q.Encode(), j.headers, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res jiraSearchResult
+		if err := json.Unmarshal(resp, &res); err != nil {
+			return nil, xerrors.Errorf("failed to decode search result: %w", err)
+		}
+
+		for _, i := range res.Issues {
+			if m := i.Properties.Tblmonit; m != nil && m.Key != "" {
+				tickets[m.Key] = ticket{ID: i.Key, ticketMeta: *m}
+			}
+		}
+		if len(res.Issues) == 0 || startAt+len(res.Issues) >= res.Total {
+			return tickets, nil
+		}
+	}
+}
+
+func (j *jira) open(ctx context.Context, title, body string, m ticketMeta) error {
+	return j.send(ctx, http.MethodPost, j.url+"/issue", map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.projectKey},
+			"issuetype":   map[string]string{"name": j.issueType},
+			"summary":     title,
+			"description": body,
+			"labels":      j.labels,
+		},
+		"properties": []map[string]interface{}{{"key": ticketLabel, "value": m}},
+	})
+}
+
+func (j *jira) comment(ctx context.Context, t ticket, body string, m ticketMeta) error {
+	if err := j.send(ctx, http.MethodPost, j.issueURL(t)+"/comment", map[string]string{"body": body}); err != nil {
+		return err
+	}
+	return j.send(ctx, http.MethodPut, j.issueURL(t)+"/properties/"+ticketLabel, m)
+}
+
+// close comments on the ticket, and transitions it to the first status in "Done" category.
+func (j *jira) close(ctx context.Context, t ticket, body string) error {
+	if err := j.send(ctx, http.MethodPost, j.issueURL(t)+"/comment", map[string]string{"body": body}); err != nil {
+		return err
+	}
+
+	resp, err := j.poster.do(ctx, http.MethodGet, j.issueURL(t)+"/transitions", j.headers, nil)
+	if err != nil {
+		return err
+	}
+	var ts jiraTransitions
+	if err := json.Unmarshal(resp, &ts); err != nil {
+		return xerrors.Errorf("failed to decode transitions: %w", err)
+	}
+	for _, tr := range ts.Transitions {
+		if tr.To.StatusCategory.Key == "done" {
+			return j.send(ctx, http.MethodPost, j.issueURL(t)+"/transitions", map[string]interface{}{
+				"transition": map[string]string{"id": tr.ID},
+			})
+		}
+	}
+	return xerrors.Errorf("no transition to done status for %s", t.ID)
+}
+
+func (j *jira) issueURL(t ticket) string {
+	return j.url + "/issue/" + url.PathEscape(t.ID)
+}
+
+func (j *jira) send(ctx context.Context, method, u string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return xerrors.Errorf("failed to encode request: %w", err)
+	}
+	// POST requests create issues, comments and transitions, so they must not be applied twice by retries
+	if method == http.MethodPost {
+		_, err = j.poster.doOnce(ctx, method, u, j.headers, body)
+		return err
+	}
+	_, err = j.poster.do(ctx, method, u, j.headers, body)
+	return err
+}
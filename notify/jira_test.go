@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJira_Notify(t *testing.T) {
+	var requests []string
+	var created map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("bot@example.com:token")), r.Header.Get("Authorization"))
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /rest/api/2/search":
+			assert.Equal(t, `project = "DATA" AND labels = "tblmonit" AND statusCategory != Done`, r.URL.Query().Get("jql"))
+			w.Write([]byte(`{"startAt": 0, "total": 1, "issues": [
+				{"key": "DATA-1", "properties": {"tblmonit": {"key": "pj.ds.fresh", "state": "stale: duration_threshold (critical)"}}}
+			]}`))
+		case "POST /rest/api/2/issue":
+			assert.NoError(t, json.Unmarshal(body, &created))
+			w.WriteHeader(http.StatusCreated)
+		case "GET /rest/api/2/issue/DATA-1/transitions":
+			w.Write([]byte(`{"transitions": [
+				{"id": "11", "to": {"statusCategory": {"key": "indeterminate"}}},
+				{"id": "31", "to": {"statusCategory": {"key": "done"}}}
+			]}`))
+		case "POST /rest/api/2/issue/DATA-1/transitions":
+			assert.JSONEq(t, `{"transition": {"id": "31"}}`, string(body))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "jira", URL: srv.URL, User: "bot@example.com", APIKey: "token", ProjectKey: "DATA"})
+	assert.NoError(t, err)
+	report := sampleReport
+	report.Results = []config.FreshnessResult{
+		{Project: "pj", Dataset: "ds", ConfigTable: "fresh", TableID: "fresh", Status: config.StatusFresh},
+		{
+			Project:     "pj",
+			Dataset:     "ds",
+			ConfigTable: "stale",
+			TableID:     "stale",
+			Status:      config.StatusStale,
+			Violations:  []config.Violation{{Rule: config.RuleExists, Reason: "Table doesn't exist", Severity: config.SeverityCritical}},
+		},
+	}
+	assert.NoError(t, n.Notify(context.Background(), report))
+
+	assert.Equal(t, []string{
+		"GET /rest/api/2/search",
+		"POST /rest/api/2/issue/DATA-1/comment",
+		"GET /rest/api/2/issue/DATA-1/transitions",
+		"POST /rest/api/2/issue/DATA-1/transitions",
+		"POST /rest/api/2/issue",
+	}, requests)
+
+	fields := created["fields"].(map[string]interface{})
+	assert.Equal(t, "[tblmonit] pj.ds.stale is not fresh", fields["summary"])
+	assert.Equal(t, map[string]interface{}{"name": "Task"}, fields["issuetype"])
+	assert.Equal(t, []interface{}{"tblmonit"}, fields["labels"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"key":   "tblmonit",
+		"value": map[string]interface{}{"key": "pj.ds.stale", "state": "stale: exists (critical)"},
+	}}, created["properties"])
+}
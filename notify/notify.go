@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/xerrors"
+)
+
+// Notifier sends freshness results to an external service.
+type Notifier interface {
+	Notify(ctx context.Context, report config.Report) error
+}
+
+// Config is a configuration of a notifier listed on the settings file.
+type Config struct {
+	Name string
+	Type string // type of the notifier, "webhook", "googlechat", "teams", "alertmanager", "opsgenie", "github" or "jira"
+	URL  string
+
+	APIKey string // for opsgenie, github and jira, environment variables are expanded
+
+	Headers  map[string]string
+	Secret   string // key for HMAC-SHA256 signature, environment variables are expanded
+	Template string // Go text/template for a request body, JSON result model is sent if empty
+
+	Timeout time.Duration
+	Retries *int // number of retries, 3 if not set and 0 disables retries
+
+	ResolveTimeout time.Duration // for alertmanager, lifetime of a firing alert unless it is sent again
+
+	Repository string        // for github, repository to file issues in the form of "owner/repo"
+	ProjectKey string        // for jira, project key to file issues
+	User       string        // for jira, user of basic authentication, environment variables are expanded
+	IssueType  string        // for jira, issue type of tickets (default: Task)
+	Labels     []string      // for github and jira, additional labels of tickets
+	OpenAfter  time.Duration // for github and jira, open a ticket when a table has been stale longer than this
+
+	Projects   []string // regular expressions of target project IDs, all projects if empty
+	Datasets   []string // regular expressions of target dataset IDs, all datasets if empty
+	Severities []string // target severities of old tables, all severities if empty
+	CatchAll   bool     // receive results routed to other notifiers by channels of the owners
+
+	DigestWindow time.Duration // batch notifications within the window into a digest
+	GroupBy      string        // group results of a digest by "project", "dataset" or "owner"
+	QuietHours   *QuietHours   // defer non-critical notifications during the hours
+}
+
+// New returns Notifier defined by given Config.
+func New(c Config) (Notifier, error) {
+	switch c.Type {
+	case "webhook":
+		return newWebhook(c)
+	case "googlechat":
+		return newGoogleChat(c)
+	case "teams":
+		return newTeams(c)
+	case "alertmanager":
+		return newAlertmanager(c)
+	case "opsgenie":
+		return newOpsgenie(c)
+	case "github":
+		return newGitHub(c)
+	case "jira":
+		return newJira(c)
+	default:
+		return nil, xerrors.Errorf("unknown notifier type: %s", c.Type)
+	}
+}
+
+// Dispatcher sends results to notifiers.
+type Dispatcher struct {
+	Notifiers []Config
+	Batches   BatchStore // store of deferred notifications, which is the alert store, nil if alert states are not tracked
+}
+
+// Dispatch sends results to each notifier whose targets match the results.
+// report is sent to notifiers that deduplicate alerts by themselves, which should be debounced by the alert states if they are tracked.
+// changes is a report of state transitions of alerts, which is sent to the other notifiers.
+// Both should be the report itself if alert states are not tracked.
+func (d *Dispatcher) Dispatch(ctx context.Context, report, changes config.Report) error {
+	var errs []error
+	for _, c := range d.Notifiers {
+		n, err := New(c)
+		if err != nil {
+			return xerrors.Errorf("failed to create notifier %s: %w", c.Name, err)
+		}
+
+		target := changes
+		if c.deduplicates() {
+			target = report
+		}
+		filtered, err := c.filter(target)
+		if err != nil {
+			return xerrors.Errorf("failed to filter results for notifier %s: %w", c.Name, err)
+		}
+
+		commit := func(delivered bool) error { return nil }
+		if c.OpenAfter > 0 && d.Batches == nil {
+			// tables which are missing without TimeThreshold can't be estimated how long they have been stale
+			return xerrors.Errorf("notifier %s opens tickets after openAfter, but alert store is not configured", c.Name)
+		}
+		if c.batches() {
+			if d.Batches == nil {
+				return xerrors.Errorf("notifier %s defers notifications, but alert store is not configured", c.Name)
+			}
+			filtered, commit, err = c.batch(d.Batches, filtered)
+			if err != nil {
+				return xerrors.Errorf("failed to batch notifications for notifier %s: %w", c.Name, err)
+			}
+		}
+
+		delivered := true
+		if len(filtered.Results) > 0 || len(filtered.Recovered) > 0 {
+			log.Info().Msgf("notify %d results to %s", len(filtered.Results), c.Name)
+			if err := n.Notify(ctx, filtered); err != nil {
+				log.Error().Err(err).Msgf("failed to notify: %s", c.Name)
+				errs = append(errs, xerrors.Errorf("failed to notify %s: %w", c.Name, err))
+				delivered = false
+			}
+		}
+		// deferred notifications are removed only after they are sent
+		if err := commit(delivered); err != nil {
+			errs = append(errs, xerrors.Errorf("failed to save deferred notifications for notifier %s: %w", c.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// deduplicates returns true if the notifier deduplicates and resolves alerts by itself,
+// so it should receive all results on every run.
+func (c *Config) deduplicates() bool {
+	switch c.Type {
+	case "alertmanager", "opsgenie", "github", "jira":
+		return true
+	default:
+		return false
+	}
+}
+
+// filter returns a report which contains only results on target projects and datasets, except silenced ones.
+func (c *Config) filter(report config.Report) (config.Report, error) {
+	projects, err := compileAll(c.Projects)
+	if err != nil {
+		return config.Report{}, err
+	}
+	datasets, err := compileAll(c.Datasets)
+	if err != nil {
+		return config.Report{}, err
+	}
+
+	match := func(rs []config.FreshnessResult) []config.FreshnessResult {
+		matched := make([]config.FreshnessResult, 0, len(rs))
+		for _, r := range rs {
+			if !r.Silenced() && matchAny(projects, r.Project) && matchAny(datasets, r.Dataset) && c.matchSeverity(r) && c.matchChannels(r) {
+				matched = append(matched, r)
+			}
+		}
+		return matched
+	}
+
+	filtered := config.Report{
+		CheckedAt: report.CheckedAt,
+		Results:   match(report.Results),
+		Totals:    report.Totals,
+	}
+	if len(report.Recovered) > 0 {
+		filtered.Recovered = match(report.Recovered)
+	}
+	return filtered, nil
+}
+
+// matchChannels returns true if the result is routed to the notifier by channels of its owner.
+// Results without channels are routed to all notifiers.
+func (c *Config) matchChannels(r config.FreshnessResult) bool {
+	if len(r.Channels) == 0 || c.CatchAll {
+		return true
+	}
+	for _, ch := range r.Channels {
+		if ch == c.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSeverity returns true if the result has no violation or its highest severity is a target.
+func (c *Config) matchSeverity(r config.FreshnessResult) bool {
+	severity := r.HighestSeverity()
+	if len(c.Severities) == 0 || severity == "" {
+		return true
+	}
+	for _, s := range c.Severities {
+		if config.Severity(s) == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	rs := make([]*regexp.Regexp, 0, len(exprs))
+	for _, e := range exprs {
+		r, err := regexp.Compile(e)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid regular expression %s: %w", e, err)
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// matchAny returns true if s matches one of rs, or rs is empty.
+func matchAny(rs []*regexp.Regexp, s string) bool {
+	if len(rs) == 0 {
+		return true
+	}
+	for _, r := range rs {
+		if r.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/alert"
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Filter(t *testing.T) {
+	tests := []struct {
+		c       Config
+		wantRes int
+	}{
+		{c: Config{}, wantRes: 2},
+		{c: Config{Projects: []string{"^pj$"}}, wantRes: 2},
+		{c: Config{Projects: []string{"other"}}, wantRes: 0},
+		{c: Config{Datasets: []string{"other", "ds"}}, wantRes: 2},
+		{c: Config{Severities: []string{"critical"}}, wantRes: 2},
+		{c: Config{Severities: []string{"warning"}}, wantRes: 1},
+	}
+	for _, tt := range tests {
+		actual, err := tt.c.filter(sampleReport)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.wantRes, len(actual.Results))
+	}
+}
+
+func TestConfig_FilterByChannels(t *testing.T) {
+	report := config.Report{Results: []config.FreshnessResult{
+		{TableID: "a", Ownership: config.Ownership{Owner: "team-a", Channels: []string{"team-a-chat"}}},
+		{TableID: "b", Ownership: config.Ownership{Owner: "team-b", Channels: []string{"team-b-chat", "team-b-pager"}}},
+		{TableID: "c"},
+	}}
+	tests := []struct {
+		c       Config
+		wantRes []string
+	}{
+		{c: Config{Name: "team-a-chat"}, wantRes: []string{"a", "c"}},
+		{c: Config{Name: "team-b-pager"}, wantRes: []string{"b", "c"}},
+		{c: Config{Name: "other"}, wantRes: []string{"c"}},
+		{c: Config{Name: "other", CatchAll: true}, wantRes: []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		actual, err := tt.c.filter(report)
+		assert.NoError(t, err)
+		tables := []string{}
+		for _, r := range actual.Results {
+			tables = append(tables, r.TableID)
+		}
+		assert.Equal(t, tt.wantRes, tables, tt.c.Name)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	received := map[string]config.Report{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report config.Report
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &report)
+		received[r.URL.Path] = report
+	}))
+	defer srv.Close()
+
+	changes := config.Report{
+		CheckedAt: sampleReport.CheckedAt,
+		Results:   []config.FreshnessResult{},
+		Recovered: []config.FreshnessResult{sampleReport.Results[0]},
+	}
+	cfgs := []Config{
+		{Name: "hook", Type: "webhook", URL: srv.URL + "/hook"},
+		{Name: "other", Type: "webhook", URL: srv.URL + "/other", Projects: []string{"other"}},
+		{Name: "am", Type: "alertmanager", URL: srv.URL},
+	}
+	d := Dispatcher{Notifiers: cfgs}
+	assert.NoError(t, d.Dispatch(context.Background(), sampleReport, changes))
+
+	assert.Equal(t, 2, len(received))
+	assert.Equal(t, 0, len(received["/hook"].Results), "webhook receives only changes")
+	assert.Equal(t, 1, len(received["/hook"].Recovered))
+	_, ok := received["/api/v2/alerts"]
+	assert.True(t, ok, "alertmanager receives all results")
+}
+
+func TestDispatch_FailedSinkKeepsAlertStates(t *testing.T) {
+	failing := true
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	succeeded := 0
+	okSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		succeeded++
+	}))
+	defer okSrv.Close()
+
+	store, err := alert.Open(filepath.Join(t.TempDir(), "alerts.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	retries := 0
+	d := Dispatcher{Notifiers: []Config{
+		{Name: "hook", Type: "webhook", URL: srv.URL, Retries: &retries},
+		{Name: "ok", Type: "webhook", URL: okSrv.URL},
+	}, Batches: store}
+	run := func(at time.Time) (config.Report, error) {
+		report := config.Report{CheckedAt: at, Results: sampleReport.Failures()}
+		tr, err := store.Update(report, 0)
+		assert.NoError(t, err)
+		dispatchErr := d.Dispatch(context.Background(), report, tr.Changes)
+		assert.NoError(t, tr.Commit(dispatchErr == nil))
+		return tr.Changes, dispatchErr
+	}
+
+	changes, err := run(sampleReport.CheckedAt)
+	assert.Error(t, err)
+	assert.Len(t, changes.Results, 1)
+
+	failing = false
+	changes, err = run(sampleReport.CheckedAt.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, changes.Results, 1, "undelivered alerts should be notified again")
+
+	changes, err = run(sampleReport.CheckedAt.Add(2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, changes.Results, 0)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 2, succeeded, "alert states are shared by notifiers, so the alerts are sent again to the notifier which succeeded")
+}
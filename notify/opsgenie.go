@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultOpsgenieURL = "https://api.opsgenie.com"
+	opsgenieSource     = "tblmonit"
+)
+
+type opsgenie struct {
+	url     string
+	headers map[string]string
+	tmpl    *template.Template
+	poster  *poster
+}
+
+func newOpsgenie(c Config) (*opsgenie, error) {
+	apiKey := os.ExpandEnv(c.APIKey)
+	if apiKey == "" {
+		return nil, xerrors.New("apiKey is required for opsgenie notifier")
+	}
+
+	u := c.URL
+	if u == "" {
+		u = defaultOpsgenieURL
+	}
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	return &opsgenie{
+		url: strings.TrimSuffix(u, "/") + "/v2/alerts",
+		headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "GenieKey " + apiKey,
+		},
+		tmpl:   tmpl,
+		poster: newPoster(c),
+	}, nil
+}
+
+// Opsgenie Alert API requests, see https://docs.opsgenie.com/docs/alert-api
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Notify creates alerts for violated rules and closes open alerts of the others.
+// Opsgenie deduplicates alerts by alias, so an alert is created only once while the rule is violated.
+// Requests are sent for all results even if some of them fail, and the first error is returned.
+// Alerts are not closed if open alerts can't be listed, but they are still created.
+func (o *opsgenie) Notify(ctx context.Context, report config.Report) error {
+	var errs []error
+	open, err := o.openAliases(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list open alerts of opsgenie")
+		errs = append(errs, err)
+	}
+
+	for _, r := range report.Results {
+		if r.Status == config.StatusError {
+			continue
+		}
+
+		violated := make(map[string]config.Violation, len(r.Violations))
+		for _, v := range r.Violations {
+			violated[v.Rule] = v
+		}
+
+		for _, rule := range config.Rules {
+			var err error
+			if v, ok := violated[rule]; ok {
+				err = o.create(ctx, r, v)
+			} else if open[opsgenieAlias(r, rule)] {
+				err = o.close(ctx, r, rule)
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("failed to send a request to opsgenie")
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// opsgenieListLimit is the maximum number of alerts in a response of List Alerts API.
+const opsgenieListLimit = 100
+
+// openAliases returns aliases of open alerts created by tblmonit.
+func (o *opsgenie) openAliases(ctx context.Context) (map[string]bool, error) {
+	aliases := make(map[string]bool)
+	for offset := 0; ; offset += opsgenieListLimit {
+		q := url.Values{}
+		q.Set("query", "status:open AND source:"+opsgenieSource)
+		q.Set("limit", fmt.Sprint(opsgenieListLimit))
+		q.Set("offset", fmt.Sprint(offset))
+		body, err := o.poster.do(ctx, http.MethodGet, o.url+"?"+q.Encode(), o.headers, nil)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to list open alerts: %w", err)
+		}
+
+		var resp struct {
+			Data []struct {
+				Alias string `json:"alias"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, xerrors.Errorf("failed to decode open alerts: %w", err)
+		}
+		for _, a := range resp.Data {
+			aliases[a.Alias] = true
+		}
+		if len(resp.Data) < opsgenieListLimit {
+			return aliases, nil
+		}
+	}
+}
+
+// opsgenieData is data passed to the template of opsgenie notifier.
+type opsgenieData struct {
+	config.FreshnessResult
+	Violation config.Violation
+	Alert     opsgenieAlert // the built-in request
+}
+
+func (o *opsgenie) create(ctx context.Context, r config.FreshnessResult, v config.Violation) error {
+	alert := opsgenieAlert{
+		Message:     fmt.Sprintf("%s is not fresh", r.FullTableID()),
+		Alias:       opsgenieAlias(r, v.Rule),
+		Description: v.Reason,
+		Tags:        []string{"tblmonit", v.Rule},
+		Details: map[string]string{
+			"rule":     v.Rule,
+			"status":   string(r.Status),
+			"severity": string(v.Severity),
+			"owner":    r.Owner,
+			"runbook":  r.Runbook,
+		},
+		Entity:   r.FullTableID(),
+		Source:   opsgenieSource,
+		Priority: opsgeniePriority(v.Severity),
+	}
+	if r.IsGroup() {
+		alert.Details["group"] = r.FullTableID()
+		alert.Details["members"] = fmt.Sprint(len(r.Members))
+	} else {
+		alert.Details["project"] = r.Project
+		alert.Details["dataset"] = r.Dataset
+		alert.Details["table"] = r.TableID
+		alert.Details["last_modified_time"] = r.LastModifiedTime.String()
+		alert.Details["num_rows"] = fmt.Sprint(r.NumRows)
+		alert.Details["console_url"] = r.ConsoleURL()
+	}
+
+	var body []byte
+	var err error
+	if o.tmpl != nil {
+		body, err = render(o.tmpl, opsgenieData{FreshnessResult: r, Violation: v, Alert: alert})
+	} else {
+		body, err = json.Marshal(alert)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to encode alert: %w", err)
+	}
+
+	if err := o.poster.post(ctx, o.url, o.headers, body); err != nil {
+		return xerrors.Errorf("failed to create alert of %s: %w", r.FullTableID(), err)
+	}
+	return nil
+}
+
+func (o *opsgenie) close(ctx context.Context, r config.FreshnessResult, rule string) error {
+	body, err := json.Marshal(opsgenieClose{
+		Source: opsgenieSource,
+		Note:   "The table became fresh",
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to encode close request: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/%s/close?identifierType=alias", o.url, url.PathEscape(opsgenieAlias(r, rule)))
+	if err := o.poster.post(ctx, u, o.headers, body); err != nil {
+		return xerrors.Errorf("failed to close alert of %s: %w", r.FullTableID(), err)
+	}
+	return nil
+}
+
+// opsgenieAlias returns the alias of the alert for the rule of the table config,
+// which is the same for all shards of a sharded table.
+func opsgenieAlias(r config.FreshnessResult, rule string) string {
+	return r.Key() + ":" + rule
+}
+
+func opsgeniePriority(s config.Severity) string {
+	if s == config.SeverityWarning {
+		return "P3"
+	}
+	return "P1"
+}
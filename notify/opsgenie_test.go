@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpsgenie_Notify(t *testing.T) {
+	var created []opsgenieAlert
+	var closed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GenieKey key", r.Header.Get("Authorization"))
+		if r.Method == http.MethodGet {
+			assert.Equal(t, "status:open AND source:tblmonit", r.URL.Query().Get("query"))
+			w.Write([]byte(`{"data": [{"alias": "pj.ds.stale:exists"}, {"alias": "pj.ds.stale:time_threshold"}, {"alias": "pj.ds.other:exists"}]}`))
+			return
+		}
+		if r.URL.Path == "/v2/alerts" {
+			var a opsgenieAlert
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &a))
+			created = append(created, a)
+		} else {
+			assert.Equal(t, "alias", r.URL.Query().Get("identifierType"))
+			closed = append(closed, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	report := sampleReport
+	report.Results = []config.FreshnessResult{
+		{
+			Project:     "pj",
+			Dataset:     "ds",
+			ConfigTable: "stale",
+			TableID:     "stale_20200102",
+			Status:      config.StatusStale,
+			Severity:    config.SeverityCritical,
+			Violations:  []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityWarning}},
+		},
+	}
+
+	n, err := New(Config{Type: "opsgenie", URL: srv.URL, APIKey: "key"})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), report))
+
+	assert.Equal(t, 1, len(created))
+	assert.Equal(t, "pj.ds.stale:duration_threshold", created[0].Alias, "the alias is the same for all shards")
+	assert.Equal(t, "P3", created[0].Priority)
+	assert.Equal(t, "not modified", created[0].Description)
+	assert.Equal(t, []string{
+		"/v2/alerts/pj.ds.stale:exists/close",
+		"/v2/alerts/pj.ds.stale:time_threshold/close",
+	}, closed, "only open alerts are closed")
+}
+
+func TestOpsgenie_NotifyPartialFailure(t *testing.T) {
+	var created []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		var a opsgenieAlert
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &a))
+		if a.Alias == "pj.ds.a:duration_threshold" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		created = append(created, a.Alias)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	stale := func(table string) config.FreshnessResult {
+		return config.FreshnessResult{
+			Project:     "pj",
+			Dataset:     "ds",
+			ConfigTable: table,
+			TableID:     table,
+			Status:      config.StatusStale,
+			Violations:  []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityCritical}},
+		}
+	}
+	report := config.Report{Results: []config.FreshnessResult{stale("a"), stale("b")}}
+
+	n, err := New(Config{Type: "opsgenie", URL: srv.URL, APIKey: "key"})
+	assert.NoError(t, err)
+	assert.Error(t, n.Notify(context.Background(), report))
+	assert.Equal(t, []string{"pj.ds.b:duration_threshold"}, created, "the other alerts are sent even if one fails")
+}
+
+func TestOpsgenie_NotifyListFailure(t *testing.T) {
+	var created []string
+	closed := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == "/v2/alerts":
+			var a opsgenieAlert
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &a))
+			created = append(created, a.Alias)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			closed++
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer srv.Close()
+
+	report := config.Report{Results: []config.FreshnessResult{{
+		Project:     "pj",
+		Dataset:     "ds",
+		ConfigTable: "stale",
+		TableID:     "stale",
+		Status:      config.StatusStale,
+		Violations:  []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityCritical}},
+	}}}
+
+	n, err := New(Config{Type: "opsgenie", URL: srv.URL, APIKey: "key"})
+	assert.NoError(t, err)
+	assert.Error(t, n.Notify(context.Background(), report))
+	assert.Equal(t, []string{"pj.ds.stale:duration_threshold"}, created, "alerts are created even if open alerts can't be listed")
+	assert.Equal(t, 0, closed)
+}
+
+func TestOpsgenie_NotifyWithTemplate(t *testing.T) {
+	var created []opsgenieAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		if r.URL.Path == "/v2/alerts" {
+			var a opsgenieAlert
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &a))
+			created = append(created, a)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	report := sampleReport
+	report.Results = []config.FreshnessResult{
+		{
+			Project:     "pj",
+			Dataset:     "ds",
+			ConfigTable: "stale",
+			TableID:     "stale",
+			Status:      config.StatusStale,
+			Ownership:   config.Ownership{Owner: "data-platform"},
+			Violations:  []config.Violation{{Rule: config.RuleDurationThreshold, Reason: "not modified", Severity: config.SeverityCritical}},
+		},
+	}
+
+	n, err := New(Config{
+		Type:     "opsgenie",
+		URL:      srv.URL,
+		APIKey:   "key",
+		Template: `{"message": "[{{.Owner}}] {{.FullTableID}}", "alias": "{{.Alert.Alias}}", "priority": "{{.Alert.Priority}}", "source": "tblmonit"}`,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), report))
+
+	assert.Equal(t, 1, len(created))
+	assert.Equal(t, opsgenieAlert{
+		Message:  "[data-platform] pj.ds.stale",
+		Alias:    "pj.ds.stale:duration_threshold",
+		Priority: "P1",
+		Source:   "tblmonit",
+	}, created[0])
+}
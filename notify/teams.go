@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+type teams struct {
+	url    string
+	tmpl   *template.Template
+	poster *poster
+}
+
+func newTeams(c Config) (*teams, error) {
+	if c.URL == "" {
+		return nil, xerrors.New("url is required for teams notifier")
+	}
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	return &teams{
+		url:    c.URL,
+		tmpl:   tmpl,
+		poster: newPoster(c),
+	}, nil
+}
+
+// Notify posts an Adaptive Card summarizing the report to Microsoft Teams incoming webhook.
+func (t *teams) Notify(ctx context.Context, report config.Report) error {
+	if len(report.Failures()) == 0 && len(report.Recovered) == 0 {
+		return nil
+	}
+
+	body, err := t.render(report)
+	if err != nil {
+		return xerrors.Errorf("failed to render message: %w", err)
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	return t.poster.post(ctx, t.url, headers, body)
+}
+
+// render returns the message rendered by the template, or the built-in message.
+func (t *teams) render(report config.Report) ([]byte, error) {
+	if t.tmpl != nil {
+		return render(t.tmpl, report)
+	}
+	return json.Marshal(newTeamsMessage(report))
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+// adaptiveCard is an Adaptive Card, see https://adaptivecards.io/explorer/
+type adaptiveCard struct {
+	Schema  string       `json:"$schema"`
+	Type    string       `json:"type"`
+	Version string       `json:"version"`
+	Body    []cardObject `json:"body"`
+}
+
+// cardObject is an element of Adaptive Card such as TextBlock and Table.
+type cardObject map[string]interface{}
+
+func textBlock(text string, bold bool) cardObject {
+	b := cardObject{"type": "TextBlock", "text": text, "wrap": true}
+	if bold {
+		b["weight"] = "Bolder"
+	}
+	return b
+}
+
+func tableRow(bold bool, texts ...string) cardObject {
+	cells := make([]cardObject, 0, len(texts))
+	for _, text := range texts {
+		cells = append(cells, cardObject{
+			"type":  "TableCell",
+			"items": []cardObject{textBlock(text, bold)},
+		})
+	}
+	return cardObject{"type": "TableRow", "cells": cells}
+}
+
+func newTeamsMessage(report config.Report) teamsMessage {
+	counts := report.TotalCounts()
+	failures := report.Failures()
+
+	rows := []cardObject{tableRow(true, "Table", "Status", "Owner", "Reason")}
+	for _, g := range report.Groups() {
+		if report.GroupBy != "" {
+			rows = append(rows, tableRow(true, fmt.Sprintf("%s: %s (%d tables)", report.GroupBy, g.Key, len(g.Results)), "", "", ""))
+		}
+		for _, r := range g.Results {
+			rows = append(rows, tableRow(false, r.FullTableID(), string(r.Status), r.Owner, strings.Join(r.Reason, "\n")))
+		}
+	}
+
+	title := fmt.Sprintf("%d tables are not fresh", len(failures))
+	if len(failures) == 0 {
+		title = fmt.Sprintf("%d tables recovered", len(report.Recovered))
+	}
+
+	body := []cardObject{
+		{
+			"type":   "TextBlock",
+			"text":   title,
+			"size":   "Large",
+			"weight": "Bolder",
+			"wrap":   true,
+		},
+		textBlock("checked at "+report.CheckedAt.Format("2006-01-02 15:04:05 MST"), false),
+		{
+			"type": "FactSet",
+			"facts": []cardObject{
+				{"title": "Fresh", "value": fmt.Sprint(counts[config.StatusFresh])},
+				{"title": "Stale", "value": fmt.Sprint(counts[config.StatusStale])},
+				{"title": "Missing", "value": fmt.Sprint(counts[config.StatusMissing])},
+				{"title": "Error", "value": fmt.Sprint(counts[config.StatusError])},
+			},
+		},
+	}
+	if len(failures) > 0 {
+		body = append(body, cardObject{
+			"type":              "Table",
+			"firstRowAsHeaders": true,
+			"columns":           []cardObject{{"width": 3}, {"width": 1}, {"width": 1}, {"width": 4}},
+			"rows":              rows,
+		})
+	}
+	if len(report.Recovered) > 0 {
+		recovered := make([]string, 0, len(report.Recovered))
+		for _, r := range report.Recovered {
+			recovered = append(recovered, "- "+r.FullTableID())
+		}
+		body = append(body, textBlock("Recovered", true), textBlock(strings.Join(recovered, "\n"), false))
+	}
+
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: adaptiveCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.5",
+				Body:    body,
+			},
+		}},
+	}
+}
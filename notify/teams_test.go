@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeams_Notify(t *testing.T) {
+	var msg map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &msg))
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "teams", URL: srv.URL})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), sampleReport))
+
+	content := msg["attachments"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})
+	body := content["body"].([]interface{})
+
+	facts := body[2].(map[string]interface{})["facts"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"title": "Fresh", "value": "1"}, facts[0])
+	assert.Equal(t, map[string]interface{}{"title": "Stale", "value": "1"}, facts[1])
+
+	rows := body[3].(map[string]interface{})["rows"].([]interface{})
+	assert.Equal(t, 2, len(rows), "header and a stale table")
+}
+
+func TestNewTeamsMessage_Totals(t *testing.T) {
+	// the report is filtered for the notifier, but the facts show all tables checked in the run
+	report := config.Report{
+		CheckedAt: sampleReport.CheckedAt,
+		Results:   sampleReport.Failures(),
+		Totals:    map[config.Status]int{config.StatusFresh: 10, config.StatusStale: 3},
+	}
+	body := newTeamsMessage(report).Attachments[0].Content.Body
+	facts := body[2]["facts"].([]cardObject)
+	assert.Equal(t, cardObject{"title": "Fresh", "value": "10"}, facts[0])
+	assert.Equal(t, cardObject{"title": "Stale", "value": "3"}, facts[1])
+}
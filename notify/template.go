@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+// parseTemplate returns the template of request bodies, or nil if the built-in payload is used.
+func (c *Config) parseTemplate() (*template.Template, error) {
+	if c.Template == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(c.Name).Funcs(config.TemplateFuncs).Parse(c.Template)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// render executes tmpl with data.
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
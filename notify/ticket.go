@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/xerrors"
+)
+
+// ticketLabel is a label of tickets filed by tblmonit, which is used to find open tickets.
+const ticketLabel = "tblmonit"
+
+// tracker is an issue tracker where tickets of old tables are filed.
+type tracker interface {
+	// openTickets returns open tickets filed by tblmonit keyed by keys of table configs.
+	openTickets(ctx context.Context) (map[string]ticket, error)
+	open(ctx context.Context, title, body string, m ticketMeta) error
+	// comment adds a comment to the ticket, and records the new state on it.
+	comment(ctx context.Context, t ticket, body string, m ticketMeta) error
+	close(ctx context.Context, t ticket, body string) error
+}
+
+// ticket is an open ticket of a table.
+type ticket struct {
+	ID   string // issue number or issue key
+	Body string // description of the ticket
+	ticketMeta
+}
+
+// ticketMeta is metadata of a ticket recorded on the issue tracker.
+type ticketMeta struct {
+	Key   string `json:"key"`   // key of the table config, see config.FreshnessResult.Key
+	State string `json:"state"` // status and violated rules of the table when it was last reported
+}
+
+var ticketMarker = regexp.MustCompile(`<!-- tblmonit:(.*?) -->`)
+
+// marker returns an HTML comment which embeds the metadata into a description written in Markdown.
+func (m ticketMeta) marker() string {
+	b, _ := json.Marshal(m)
+	return fmt.Sprintf("<!-- tblmonit:%s -->", b)
+}
+
+// parseTicketMeta returns the metadata embedded into the description, or false if there is none.
+func parseTicketMeta(body string) (ticketMeta, bool) {
+	match := ticketMarker.FindStringSubmatch(body)
+	if match == nil {
+		return ticketMeta{}, false
+	}
+	var m ticketMeta
+	if err := json.Unmarshal([]byte(match[1]), &m); err != nil || m.Key == "" {
+		return ticketMeta{}, false
+	}
+	return m, true
+}
+
+// ticketData is data passed to the template of ticket descriptions.
+type ticketData struct {
+	config.FreshnessResult
+	CheckedAt  time.Time
+	StaleSince time.Time
+}
+
+const defaultTicketTemplate = `{{.FullTableID}} is {{.Status}} since {{.StaleSince.Format "2006-01-02 15:04:05 MST"}}.
+{{range .Violations}}
+- [{{.Severity}}] {{.Reason}}{{end}}
+
+Last modified time: {{if .LastModifiedTime.IsZero}}-{{else}}{{.LastModifiedTime.Format "2006-01-02 15:04:05 MST"}}{{end}}
+Number of rows: {{.NumRows}}
+Owner: {{with .Owner}}{{.}}{{else}}-{{end}}
+Runbook: {{with .Runbook}}{{.}}{{else}}-{{end}}
+BigQuery console: {{with .ConsoleURL}}{{.}}{{else}}-{{end}}
+Checked at: {{.CheckedAt.Format "2006-01-02 15:04:05 MST"}}`
+
+// ticketNotifier files a ticket for each table which has been stale longer than openAfter,
+// comments on it when the state of the table changes, and closes it when the table becomes fresh.
+type ticketNotifier struct {
+	tracker   tracker
+	openAfter time.Duration
+	tmpl      *template.Template
+}
+
+func newTicketNotifier(c Config, t tracker) (*ticketNotifier, error) {
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		tmpl = template.Must(template.New(c.Name).Funcs(config.TemplateFuncs).Parse(defaultTicketTemplate))
+	}
+	return &ticketNotifier{tracker: t, openAfter: c.OpenAfter, tmpl: tmpl}, nil
+}
+
+// Notify files, updates and closes tickets by the report.
+// Tables which failed to be checked are skipped because their status is unknown.
+// Tickets of all tables are updated even if some of them fail, and the first error is returned.
+func (n *ticketNotifier) Notify(ctx context.Context, report config.Report) error {
+	tickets, err := n.tracker.openTickets(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to find open tickets: %w", err)
+	}
+
+	var errs []error
+	for _, r := range report.Results {
+		if r.Status == config.StatusError {
+			continue
+		}
+		t, ok := tickets[r.Key()]
+		if err := n.update(ctx, r, t, ok, report.CheckedAt); err != nil {
+			log.Error().Err(err).Msg("failed to update a ticket")
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// update files, comments on or closes the ticket of the table. found is false if the table has no open ticket.
+func (n *ticketNotifier) update(ctx context.Context, r config.FreshnessResult, t ticket, found bool, checkedAt time.Time) error {
+	if len(r.Violations) == 0 {
+		if found {
+			body := fmt.Sprintf("%s became fresh at %s.", r.FullTableID(), checkedAt.Format("2006-01-02 15:04:05 MST"))
+			if err := n.tracker.close(ctx, t, body); err != nil {
+				return xerrors.Errorf("failed to close ticket of %s: %w", r.Key(), err)
+			}
+		}
+		return nil
+	}
+
+	since := staleSince(r, checkedAt)
+	if !found && checkedAt.Sub(since) < n.openAfter {
+		return nil
+	}
+	m := ticketMeta{Key: r.Key(), State: ticketState(r)}
+	if found && t.State == m.State {
+		return nil
+	}
+
+	body, err := render(n.tmpl, ticketData{FreshnessResult: r, CheckedAt: checkedAt, StaleSince: since})
+	if err != nil {
+		return xerrors.Errorf("failed to render ticket of %s: %w", r.Key(), err)
+	}
+	if found {
+		err = n.tracker.comment(ctx, t, string(body), m)
+	} else {
+		err = n.tracker.open(ctx, fmt.Sprintf("[tblmonit] %s is not fresh", r.Key()), string(body), m)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to update ticket of %s: %w", r.Key(), err)
+	}
+	return nil
+}
+
+// ticketState returns the status and violated rules of the table, which are commented on the ticket when they change.
+func ticketState(r config.FreshnessResult) string {
+	rules := make([]string, 0, len(r.Violations))
+	for _, v := range r.Violations {
+		rules = append(rules, fmt.Sprintf("%s (%s)", v.Rule, v.Severity))
+	}
+	return fmt.Sprintf("%s: %s", r.Status, strings.Join(rules, ", "))
+}
+
+// staleSince returns the time when the table became not fresh.
+// It is the first time observed by the alert store if it is tracked, otherwise estimated by the thresholds.
+// The estimate is checkedAt for missing tables without TimeThreshold, and the earliest one of the members for incidents.
+func staleSince(r config.FreshnessResult, checkedAt time.Time) time.Time {
+	if !r.StaleSince.IsZero() {
+		return r.StaleSince
+	}
+	since := checkedAt
+	earlier := func(t time.Time) {
+		if t.Before(since) {
+			since = t
+		}
+	}
+	for _, v := range r.Violations {
+		switch v.Rule {
+		case config.RuleExists, config.RuleTimeThreshold:
+			if r.TimeThreshold != nil {
+				earlier(r.TimeThreshold.Time)
+			}
+		case config.RuleWarningTimeThreshold:
+			if r.WarningTimeThreshold != nil {
+				earlier(r.WarningTimeThreshold.Time)
+			}
+		case config.RuleDurationThreshold:
+			if r.DurationThreshold != nil {
+				earlier(r.LastModifiedTime.Add(r.DurationThreshold.Duration))
+			}
+		case config.RuleWarningDurationThreshold:
+			if r.WarningDurationThreshold != nil {
+				earlier(r.LastModifiedTime.Add(r.WarningDurationThreshold.Duration))
+			}
+		}
+	}
+	for _, m := range r.Members {
+		earlier(staleSince(m, checkedAt))
+	}
+	return since
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"text/template"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+// SignatureHeader is a header name of HMAC-SHA256 signature of a webhook request body.
+const SignatureHeader = "X-Tblmonit-Signature"
+
+type webhook struct {
+	url     string
+	headers map[string]string
+	secret  string
+	tmpl    *template.Template
+	poster  *poster
+}
+
+func newWebhook(c Config) (*webhook, error) {
+	if c.URL == "" {
+		return nil, xerrors.New("url is required for webhook notifier")
+	}
+
+	w := &webhook{
+		url:     c.URL,
+		headers: map[string]string{"Content-Type": "application/json"},
+		secret:  os.ExpandEnv(c.Secret),
+		poster:  newPoster(c),
+	}
+	for k, v := range c.Headers {
+		w.headers[k] = os.ExpandEnv(v)
+	}
+
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	w.tmpl = tmpl
+	return w, nil
+}
+
+// Notify posts the report to the webhook URL if any table is not fresh or recovered.
+func (w *webhook) Notify(ctx context.Context, report config.Report) error {
+	if len(report.Failures()) == 0 && len(report.Recovered) == 0 {
+		return nil
+	}
+
+	body, err := w.render(report)
+	if err != nil {
+		return xerrors.Errorf("failed to render request body: %w", err)
+	}
+
+	headers := make(map[string]string, len(w.headers)+1)
+	for k, v := range w.headers {
+		headers[k] = v
+	}
+	if w.secret != "" {
+		headers[SignatureHeader] = "sha256=" + sign(w.secret, body)
+	}
+
+	return w.poster.post(ctx, w.url, headers, body)
+}
+
+func (w *webhook) render(report config.Report) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(report)
+	}
+	return render(w.tmpl, report)
+}
+
+// sign returns hex encoded HMAC-SHA256 of body.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
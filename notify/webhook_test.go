@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	retryWait = time.Millisecond
+}
+
+var sampleReport = config.Report{
+	CheckedAt: time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC),
+	Results: []config.FreshnessResult{
+		{
+			Table:   "pj.ds.fresh",
+			Project: "pj",
+			Dataset: "ds",
+			TableID: "fresh",
+			Status:  config.StatusFresh,
+		},
+		{
+			Table:   "pj.ds.stale",
+			Project: "pj",
+			Dataset: "ds",
+			TableID: "stale",
+			Status:  config.StatusStale,
+			Reason:  []string{"The table should be modified in 1h0m0s, but not modified in 1h30m0s"},
+			Violations: []config.Violation{{
+				Rule:     config.RuleDurationThreshold,
+				Reason:   "The table should be modified in 1h0m0s, but not modified in 1h30m0s",
+				Severity: config.SeverityCritical,
+			}},
+		},
+	},
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	requests := 0
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{
+		Type:    "webhook",
+		URL:     srv.URL,
+		Secret:  "secret",
+		Headers: map[string]string{"X-Custom": "value"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), sampleReport))
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, "value", header.Get("X-Custom"))
+	assert.Equal(t, "sha256="+sign("secret", body), header.Get(SignatureHeader))
+
+	var got config.Report
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, sampleReport, got)
+}
+
+func TestWebhook_NotifyWithTemplate(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{
+		Type:     "webhook",
+		URL:      srv.URL,
+		Template: `{{range .Failures}}{{.Table}}: {{json .Reason}}{{end}}`,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Notify(context.Background(), sampleReport))
+	assert.Equal(t, `pj.ds.stale: ["The table should be modified in 1h0m0s, but not modified in 1h30m0s"]`, string(body))
+}
+
+func TestWebhook_NotifyClientError(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "webhook", URL: srv.URL})
+	assert.NoError(t, err)
+	assert.Error(t, n.Notify(context.Background(), sampleReport))
+	assert.Equal(t, 1, requests, "client errors should not be retried")
+}
+
+func TestWebhook_NotifyWithoutRetries(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	retries := 0
+	n, err := New(Config{Type: "webhook", URL: srv.URL, Retries: &retries})
+	assert.NoError(t, err)
+	assert.Error(t, n.Notify(context.Background(), sampleReport))
+	assert.Equal(t, 1, requests, "retries should be disabled")
+}
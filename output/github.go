@@ -0,0 +1,97 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hirosassa/tblmonit/config"
+)
+
+var (
+	githubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// WriteGitHubAnnotations writes workflow commands of GitHub Actions which annotate old tables.
+// Critical tables and tables which failed to be checked are errors, and warning tables are warnings.
+// Silenced tables are not annotated.
+// See https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+func WriteGitHubAnnotations(w io.Writer, report config.Report) error {
+	var b strings.Builder
+	for _, r := range report.Failures() {
+		if r.Silenced() || (r.Status != config.StatusError && len(r.Violations) == 0) {
+			continue
+		}
+
+		command := "warning"
+		if r.HighestSeverity() == config.SeverityCritical {
+			command = "error"
+		}
+		title := fmt.Sprintf("%s is %s", r.FullTableID(), r.Status)
+		fmt.Fprintf(&b, "::%s title=%s::%s\n", command, githubPropertyEscaper.Replace(title), githubDataEscaper.Replace(strings.Join(r.Reason, "\n")))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// githubIcon returns an emoji of the status of the table.
+func githubIcon(r config.FreshnessResult) string {
+	switch {
+	case r.Status == config.StatusError:
+		return ":boom:"
+	case len(r.Violations) == 0:
+		return ":white_check_mark:"
+	case r.Silenced():
+		return ":no_bell:"
+	case r.HighestSeverity() == config.SeverityCritical:
+		return ":x:"
+	}
+	return ":warning:"
+}
+
+var markdownCellEscaper = strings.NewReplacer("|", `\|`, "\n", "<br>", "\r", "")
+
+// WriteGitHubSummary writes all results of the report in Markdown for the job summary of GitHub Actions.
+// Results are grouped by dataset in order of their first appearance.
+func WriteGitHubSummary(w io.Writer, report config.Report) error {
+	var b strings.Builder
+	b.WriteString("## Freshness of tables\n\n")
+
+	counts := report.Counts()
+	fmt.Fprintf(&b, "Checked at %s: %d fresh, %d stale, %d missing, %d error\n",
+		report.CheckedAt.Format("2006-01-02 15:04:05 MST"),
+		counts[config.StatusFresh], counts[config.StatusStale], counts[config.StatusMissing], counts[config.StatusError])
+
+	datasets := make([]string, 0)
+	results := make(map[string][]config.FreshnessResult)
+	for _, r := range report.Results {
+		key := r.Project + "." + r.Dataset
+		if _, ok := results[key]; !ok {
+			datasets = append(datasets, key)
+		}
+		results[key] = append(results[key], r)
+	}
+
+	for _, ds := range datasets {
+		fmt.Fprintf(&b, "\n### %s\n\n", ds)
+		b.WriteString("| | Table | Status | Last modified | Reason |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+		for _, r := range results[ds] {
+			lastModified := "-"
+			if !r.LastModifiedTime.IsZero() {
+				lastModified = r.LastModifiedTime.Format("2006-01-02 15:04:05 MST")
+			}
+			status := string(r.Status)
+			if r.Silenced() {
+				status += fmt.Sprintf(" (silenced by %s)", r.SilencedBy)
+			}
+			fmt.Fprintf(&b, "| %s | [%s](%s) | %s | %s | %s |\n",
+				githubIcon(r), markdownCellEscaper.Replace(r.TableID), r.ConsoleURL(),
+				markdownCellEscaper.Replace(status), lastModified, markdownCellEscaper.Replace(strings.Join(r.Reason, "\n")))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,44 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	silenced := sampleReport.Results[2]
+	silenced.SilencedBy = "maintenance"
+	multiline := sampleReport.Results[2]
+	multiline.Dataset, multiline.Reason = "a,b", []string{"100% missing", "really"}
+	report := sampleReport
+	report.Results = append(append([]config.FreshnessResult{}, sampleReport.Results...), silenced, multiline)
+
+	var b strings.Builder
+	assert.NoError(t, WriteGitHubAnnotations(&b, report))
+	assert.Equal(t, `::warning title=pj.ds.sharded_20200101 is stale::not modified in 1h30m0s
+::error title=pj.other.missing is missing::Table doesn't exist
+::error title=pj.other.error is error::Failed to fetch metadata: forbidden
+::error title=pj.a%2Cb.missing is missing::100%25 missing%0Areally
+`, b.String())
+}
+
+func TestWriteGitHubSummary(t *testing.T) {
+	var b strings.Builder
+	assert.NoError(t, WriteGitHubSummary(&b, sampleReport))
+	assert.Equal(t, "## Freshness of tables\n\n"+
+		"Checked at 2020-01-02 09:00:00 UTC: 1 fresh, 1 stale, 1 missing, 1 error\n\n"+
+		"### pj.ds\n\n"+
+		"| | Table | Status | Last modified | Reason |\n"+
+		"|---|---|---|---|---|\n"+
+		"| :white_check_mark: | [fresh]("+sampleReport.Results[0].ConsoleURL()+") | fresh | 2020-01-02 08:30:00 UTC |  |\n"+
+		"| :warning: | [sharded_20200101]("+sampleReport.Results[1].ConsoleURL()+") | stale | 2020-01-02 07:30:00 UTC | not modified in 1h30m0s |\n\n"+
+		"### pj.other\n\n"+
+		"| | Table | Status | Last modified | Reason |\n"+
+		"|---|---|---|---|---|\n"+
+		"| :x: | [missing]("+sampleReport.Results[2].ConsoleURL()+") | missing | - | Table doesn't exist |\n"+
+		"| :boom: | [error]("+sampleReport.Results[3].ConsoleURL()+") | error | - | Failed to fetch metadata: forbidden |\n",
+		b.String())
+}
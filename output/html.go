@@ -0,0 +1,316 @@
+package output
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/hirosassa/tblmonit/history"
+)
+
+// sparkline dimensions in pixels
+const (
+	sparklineBarWidth = 4
+	sparklineHeight   = 20
+)
+
+// htmlReport is data passed to the HTML template.
+type htmlReport struct {
+	CheckedAt time.Time
+	Counts    htmlCounts
+	Silenced  int
+	History   bool // true if timelines are given
+	Projects  []htmlProject
+}
+
+type htmlCounts struct {
+	Fresh, Stale, Missing, Error int
+}
+
+type htmlProject struct {
+	ID       string
+	Datasets []htmlDataset
+}
+
+type htmlDataset struct {
+	ID   string
+	Rows []htmlRow
+}
+
+type htmlRow struct {
+	config.FreshnessResult
+	State      string // fresh, warning, critical, error or silenced
+	Lag        string
+	Thresholds []string
+	Sparkline  *sparkline
+}
+
+type sparkline struct {
+	Width  int
+	Height int
+	Bars   []sparklineBar
+}
+
+type sparklineBar struct {
+	X, Y, Width, Height int
+	State               string
+	Title               string
+}
+
+// resultState returns the state of the table to highlight it, which is also used as a CSS class and a filter of the html format.
+func resultState(r config.FreshnessResult) string {
+	switch {
+	case r.Status == config.StatusError:
+		return "error"
+	case len(r.Violations) == 0:
+		return "fresh"
+	case r.Silenced():
+		return "silenced"
+	case r.HighestSeverity() == config.SeverityCritical:
+		return "critical"
+	}
+	return "warning"
+}
+
+// formatLag returns the duration in a short form such as "2d3h" or "1h30m".
+func formatLag(d time.Duration) string {
+	d = d.Round(time.Minute)
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd%dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", d/time.Hour, d%time.Hour/time.Minute)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+// thresholds returns the thresholds of the table in a human readable form.
+func thresholds(r config.FreshnessResult) []string {
+	ths := make([]string, 0, 4)
+	if r.TimeThreshold != nil {
+		ths = append(ths, "by "+r.TimeThreshold.Format("15:04"))
+	}
+	if r.DurationThreshold != nil {
+		ths = append(ths, "every "+formatLag(r.DurationThreshold.Duration))
+	}
+	if r.WarningTimeThreshold != nil {
+		ths = append(ths, "warn by "+r.WarningTimeThreshold.Format("15:04"))
+	}
+	if r.WarningDurationThreshold != nil {
+		ths = append(ths, "warn every "+formatLag(r.WarningDurationThreshold.Duration))
+	}
+	return ths
+}
+
+// newSparkline returns bars of the records whose heights are their lags relative to the largest one.
+// Records of missing tables are full height, and records which failed to be checked are empty.
+func newSparkline(records []history.Record) *sparkline {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var max time.Duration
+	for _, rec := range records {
+		if lag := rec.CheckedAt.Sub(rec.LastModifiedTime); !rec.LastModifiedTime.IsZero() && lag > max {
+			max = lag
+		}
+	}
+
+	s := &sparkline{Width: len(records) * sparklineBarWidth, Height: sparklineHeight}
+	for i, rec := range records {
+		height := sparklineHeight
+		title := fmt.Sprintf("%s: %s", rec.CheckedAt.Format("2006-01-02 15:04"), rec.Status)
+		switch {
+		case rec.Status == config.StatusError:
+			height = 1
+		case !rec.LastModifiedTime.IsZero() && max > 0:
+			lag := rec.CheckedAt.Sub(rec.LastModifiedTime)
+			height = int(float64(sparklineHeight) * float64(lag) / float64(max))
+			if height < 1 {
+				height = 1
+			}
+			title += ", lag " + formatLag(lag)
+		}
+		s.Bars = append(s.Bars, sparklineBar{
+			X:      i * sparklineBarWidth,
+			Y:      sparklineHeight - height,
+			Width:  sparklineBarWidth - 1,
+			Height: height,
+			State:  string(rec.Status),
+			Title:  title,
+		})
+	}
+	return s
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlTemplateText))
+
+// WriteHTML writes the report as a self-contained HTML page.
+// Tables are grouped by project and dataset in order of their first appearance.
+// If timelines keyed by table configs are given, each table has a sparkline of its recent lags.
+func WriteHTML(w io.Writer, report config.Report, timelines map[string][]history.Record) error {
+	counts := report.Counts()
+	data := htmlReport{
+		CheckedAt: report.CheckedAt,
+		Counts: htmlCounts{
+			Fresh:   counts[config.StatusFresh],
+			Stale:   counts[config.StatusStale],
+			Missing: counts[config.StatusMissing],
+			Error:   counts[config.StatusError],
+		},
+		History: timelines != nil,
+	}
+
+	projects := make(map[string]int)
+	datasets := make(map[string]int)
+	for _, r := range report.Results {
+		pi, ok := projects[r.Project]
+		if !ok {
+			pi = len(data.Projects)
+			projects[r.Project] = pi
+			data.Projects = append(data.Projects, htmlProject{ID: r.Project})
+		}
+		pj := &data.Projects[pi]
+
+		key := r.Project + "." + r.Dataset
+		di, ok := datasets[key]
+		if !ok {
+			di = len(pj.Datasets)
+			datasets[key] = di
+			pj.Datasets = append(pj.Datasets, htmlDataset{ID: r.Dataset})
+		}
+
+		row := htmlRow{FreshnessResult: r, State: resultState(r), Thresholds: thresholds(r), Sparkline: newSparkline(timelines[r.Key()])}
+		if !r.LastModifiedTime.IsZero() {
+			row.Lag = formatLag(report.CheckedAt.Sub(r.LastModifiedTime))
+		}
+		if r.Silenced() {
+			data.Silenced++
+		}
+		pj.Datasets[di].Rows = append(pj.Datasets[di].Rows, row)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+const htmlTemplateText = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Freshness of tables</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+h1 { margin-bottom: 0.2em; }
+.summary span { display: inline-block; margin-right: 1em; padding: 0.2em 0.6em; border-radius: 4px; }
+.filters { margin: 1em 0; }
+.filters input, .filters select { padding: 0.3em; margin-right: 0.5em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+th { background: #f6f8fa; }
+td.status { font-weight: bold; white-space: nowrap; }
+.fresh { background: #dafbe1; }
+.warning { background: #fff8c5; }
+.critical { background: #ffebe9; }
+.error { background: #eaeef2; }
+.silenced { background: #f6f8fa; color: #57606a; }
+ul.reasons, ul.thresholds { margin: 0; padding-left: 1.2em; }
+svg rect.fresh { fill: #2da44e; }
+svg rect.stale, svg rect.missing { fill: #cf222e; }
+svg rect.error { fill: #8c959f; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<h1>Freshness of tables</h1>
+<p>Checked at <time datetime="{{.CheckedAt.Format "2006-01-02T15:04:05Z07:00"}}" class="relative">{{.CheckedAt.Format "2006-01-02 15:04:05 MST"}}</time></p>
+<p class="summary">
+<span class="fresh">{{.Counts.Fresh}} fresh</span>
+<span class="critical">{{.Counts.Stale}} stale</span>
+<span class="critical">{{.Counts.Missing}} missing</span>
+<span class="error">{{.Counts.Error}} error</span>
+<span class="silenced">{{.Silenced}} silenced</span>
+</p>
+<div class="filters">
+<input type="search" id="filter-text" placeholder="Filter tables">
+<select id="filter-state">
+<option value="">all states</option>
+<option value="not-fresh">not fresh</option>
+<option value="fresh">fresh</option>
+<option value="warning">warning</option>
+<option value="critical">critical</option>
+<option value="error">error</option>
+<option value="silenced">silenced</option>
+</select>
+</div>
+{{range .Projects}}{{$project := .ID}}
+<section class="project">
+<h2>{{.ID}}</h2>
+{{range .Datasets}}
+<section class="dataset">
+<h3>{{$project}}.{{.ID}}</h3>
+<table>
+<thead>
+<tr><th>Status</th><th>Table</th><th>Last modified</th><th>Lag</th><th>Thresholds</th><th>Reason</th>{{if $.History}}<th>History</th>{{end}}</tr>
+</thead>
+<tbody>
+{{range .Rows}}
+<tr class="{{.State}}" data-state="{{.State}}" data-table="{{.FullTableID}}">
+<td class="status">{{.Status}}{{if .Silenced}} (silenced by {{.SilencedBy}}){{end}}</td>
+<td><a href="{{.ConsoleURL}}">{{.TableID}}</a>{{with .Owner}}<br><small>owner: {{.}}</small>{{end}}{{with .Runbook}}<br><small><a href="{{.}}">runbook</a></small>{{end}}</td>
+<td>{{if .LastModifiedTime.IsZero}}-{{else}}<time datetime="{{.LastModifiedTime.Format "2006-01-02T15:04:05Z07:00"}}" title="{{.LastModifiedTime.Format "2006-01-02 15:04:05 MST"}}" class="relative">{{.LastModifiedTime.Format "2006-01-02 15:04:05 MST"}}</time>{{end}}</td>
+<td>{{with .Lag}}{{.}}{{else}}-{{end}}</td>
+<td>{{if .Thresholds}}<ul class="thresholds">{{range .Thresholds}}<li>{{.}}</li>{{end}}</ul>{{else}}-{{end}}</td>
+<td>{{if .Reason}}<ul class="reasons">{{range .Reason}}<li>{{.}}</li>{{end}}</ul>{{end}}</td>
+{{if $.History}}<td>{{with .Sparkline}}<svg width="{{.Width}}" height="{{.Height}}" role="img">{{range .Bars}}<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}" class="{{.State}}"><title>{{.Title}}</title></rect>{{end}}</svg>{{end}}</td>{{end}}
+</tr>
+{{end}}
+</tbody>
+</table>
+</section>
+{{end}}
+</section>
+{{end}}
+<script>
+(function () {
+  function relative(date) {
+    var seconds = Math.round((Date.now() - date.getTime()) / 1000);
+    var units = [["day", 86400], ["hour", 3600], ["minute", 60]];
+    for (var i = 0; i < units.length; i++) {
+      var n = Math.floor(Math.abs(seconds) / units[i][1]);
+      if (n >= 1) {
+        var text = n + " " + units[i][0] + (n > 1 ? "s" : "");
+        return seconds >= 0 ? text + " ago" : "in " + text;
+      }
+    }
+    return "just now";
+  }
+  document.querySelectorAll("time.relative").forEach(function (el) {
+    el.title = el.textContent;
+    el.textContent = relative(new Date(el.getAttribute("datetime")));
+  });
+
+  var text = document.getElementById("filter-text");
+  var state = document.getElementById("filter-state");
+  function filter() {
+    var q = text.value.toLowerCase();
+    var s = state.value;
+    document.querySelectorAll("tbody tr").forEach(function (tr) {
+      var st = tr.getAttribute("data-state");
+      var matched = tr.getAttribute("data-table").toLowerCase().indexOf(q) >= 0 &&
+        (s === "" || s === st || (s === "not-fresh" && st !== "fresh"));
+      tr.classList.toggle("hidden", !matched);
+    });
+    document.querySelectorAll("section.dataset, section.project").forEach(function (sec) {
+      sec.classList.toggle("hidden", sec.querySelector("tbody tr:not(.hidden)") === null);
+    });
+  }
+  text.addEventListener("input", filter);
+  state.addEventListener("change", filter);
+})();
+</script>
+</body>
+</html>
+`
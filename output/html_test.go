@@ -0,0 +1,73 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/hirosassa/tblmonit/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatLag(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 30 * time.Second, want: "1m"},
+		{d: 90 * time.Minute, want: "1h30m"},
+		{d: 47 * time.Hour, want: "47h0m"},
+		{d: 50 * time.Hour, want: "2d2h"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatLag(tt.d))
+	}
+}
+
+func TestNewSparkline(t *testing.T) {
+	records := []history.Record{
+		{CheckedAt: checkedAt.Add(-2 * time.Hour), Status: config.StatusFresh, LastModifiedTime: checkedAt.Add(-3 * time.Hour)},
+		{CheckedAt: checkedAt.Add(-time.Hour), Status: config.StatusError},
+		{CheckedAt: checkedAt, Status: config.StatusMissing},
+		{CheckedAt: checkedAt, Status: config.StatusStale, LastModifiedTime: checkedAt.Add(-2 * time.Hour)},
+	}
+	s := newSparkline(records)
+	assert.Equal(t, 16, s.Width)
+	heights := make([]int, 0, len(s.Bars))
+	for _, b := range s.Bars {
+		heights = append(heights, b.Height)
+		assert.Equal(t, sparklineHeight, b.Y+b.Height)
+	}
+	assert.Equal(t, []int{10, 1, 20, 20}, heights)
+	assert.Equal(t, "2020-01-02 07:00: fresh, lag 1h0m", s.Bars[0].Title)
+
+	assert.Nil(t, newSparkline(nil))
+}
+
+func TestWriteHTML(t *testing.T) {
+	report := sampleReport
+	report.Results = append([]config.FreshnessResult{}, sampleReport.Results...)
+	report.Results[2].SilencedBy = "<maintenance>"
+
+	var b strings.Builder
+	assert.NoError(t, WriteHTML(&b, report, nil))
+	html := b.String()
+	assert.Contains(t, html, `<span class="fresh">1 fresh</span>`)
+	assert.Contains(t, html, `<span class="silenced">1 silenced</span>`)
+	assert.Contains(t, html, `<h3>pj.ds</h3>`)
+	assert.Contains(t, html, `<h3>pj.other</h3>`)
+	assert.Contains(t, html, `<tr class="warning" data-state="warning" data-table="pj.ds.sharded_20200101">`)
+	assert.Contains(t, html, `<td>1h30m</td>`)
+	assert.Contains(t, html, `<li>every 2h0m</li><li>warn every 1h0m</li>`)
+	assert.Contains(t, html, `missing (silenced by &lt;maintenance&gt;)`)
+	assert.NotContains(t, html, `<th>History</th>`)
+
+	b.Reset()
+	timelines := map[string][]history.Record{
+		"pj.ds.fresh": {{CheckedAt: checkedAt, Status: config.StatusFresh, LastModifiedTime: checkedAt.Add(-time.Hour)}},
+	}
+	assert.NoError(t, WriteHTML(&b, report, timelines))
+	assert.Contains(t, b.String(), `<th>History</th>`)
+	assert.Contains(t, b.String(), `<svg width="4" height="20" role="img"><rect x="0" y="0" width="3" height="20" class="fresh"><title>2020-01-02 09:00: fresh, lag 1h0m</title></rect></svg>`)
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hirosassa/tblmonit/config"
+)
+
+// JUnit XML, see https://github.com/testmoapp/junitxml
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report in JUnit XML format.
+// Each project.dataset is a test suite, and each table config is a test case of it.
+// Old tables are failures with their reasons, tables which failed to be checked are errors,
+// and silenced old tables are skipped.
+func WriteJUnit(w io.Writer, report config.Report) error {
+	timestamp := report.CheckedAt.Format("2006-01-02T15:04:05")
+	suites := junitTestSuites{Name: "tblmonit", Timestamp: timestamp}
+	index := make(map[string]int)
+	for _, r := range report.Results {
+		name := r.Project + "." + r.Dataset
+		i, ok := index[name]
+		if !ok {
+			i = len(suites.TestSuites)
+			index[name] = i
+			suites.TestSuites = append(suites.TestSuites, junitTestSuite{Name: name, Timestamp: timestamp})
+		}
+		suite := &suites.TestSuites[i]
+
+		tc := junitTestCase{Name: r.ConfigTable, ClassName: name, SystemOut: junitSystemOut(r)}
+		reason := strings.Join(r.Reason, "\n")
+		switch {
+		case r.Status == config.StatusError:
+			tc.Error = &junitMessage{Message: firstLine(r.Reason), Type: string(r.Status), Text: reason}
+			suite.Errors++
+		case len(r.Violations) > 0 && r.Silenced():
+			tc.Skipped = &junitMessage{Message: fmt.Sprintf("%s is %s, but silenced by %s", r.FullTableID(), r.Status, r.SilencedBy)}
+			suite.Skipped++
+		case len(r.Violations) > 0:
+			tc.Failure = &junitMessage{Message: firstLine(r.Reason), Type: string(r.Status), Text: junitFailure(r)}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	for _, s := range suites.TestSuites {
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Errors += s.Errors
+		suites.Skipped += s.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func firstLine(reasons []string) string {
+	if len(reasons) == 0 {
+		return ""
+	}
+	return reasons[0]
+}
+
+// junitFailure returns the body of the failure, which lists the violations with their severities.
+func junitFailure(r config.FreshnessResult) string {
+	lines := make([]string, 0, len(r.Violations))
+	for _, v := range r.Violations {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", v.Severity, v.Rule, v.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// junitSystemOut returns the metadata of the table shown with the test case.
+func junitSystemOut(r config.FreshnessResult) string {
+	lines := []string{"table: " + r.FullTableID()}
+	if !r.LastModifiedTime.IsZero() {
+		lines = append(lines, "last modified time: "+r.LastModifiedTime.Format("2006-01-02 15:04:05 MST"))
+	}
+	if r.Owner != "" {
+		lines = append(lines, "owner: "+r.Owner)
+	}
+	if r.Runbook != "" {
+		lines = append(lines, "runbook: "+r.Runbook)
+	}
+	return strings.Join(lines, "\n")
+}
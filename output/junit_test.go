@@ -0,0 +1,46 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	report := sampleReport
+	silenced := report.Results[2]
+	silenced.ConfigTable, silenced.TableID, silenced.SilencedBy = "silenced", "silenced", "maintenance"
+	report.Results = append(append([]config.FreshnessResult{}, sampleReport.Results...), silenced)
+
+	var b strings.Builder
+	assert.NoError(t, WriteJUnit(&b, report))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="tblmonit" tests="5" failures="2" errors="1" skipped="1" timestamp="2020-01-02T09:00:00">
+  <testsuite name="pj.ds" tests="2" failures="1" errors="0" skipped="0" timestamp="2020-01-02T09:00:00">
+    <testcase name="fresh" classname="pj.ds">
+      <system-out>table: pj.ds.fresh&#xA;last modified time: 2020-01-02 08:30:00 UTC</system-out>
+    </testcase>
+    <testcase name="sharded_" classname="pj.ds">
+      <failure message="not modified in 1h30m0s" type="stale">[warning] warning_duration_threshold: not modified in 1h30m0s</failure>
+      <system-out>table: pj.ds.sharded_20200101&#xA;last modified time: 2020-01-02 07:30:00 UTC&#xA;owner: data-platform</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="pj.other" tests="3" failures="1" errors="1" skipped="1" timestamp="2020-01-02T09:00:00">
+    <testcase name="missing" classname="pj.other">
+      <failure message="Table doesn&#39;t exist" type="missing">[critical] exists: Table doesn&#39;t exist</failure>
+      <system-out>table: pj.other.missing</system-out>
+    </testcase>
+    <testcase name="error" classname="pj.other">
+      <error message="Failed to fetch metadata: forbidden" type="error">Failed to fetch metadata: forbidden</error>
+      <system-out>table: pj.other.error</system-out>
+    </testcase>
+    <testcase name="silenced" classname="pj.other">
+      <skipped message="pj.other.silenced is missing, but silenced by maintenance"></skipped>
+      <system-out>table: pj.other.silenced</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, b.String())
+}
@@ -0,0 +1,121 @@
+// Package output renders freshness reports in formats of other tools such as monitoring systems and CI.
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+)
+
+// NagiosState is a state of a service check, which is also the exit code of Nagios plugins.
+type NagiosState int
+
+// States of Nagios plugins, see https://nagios-plugins.org/doc/guidelines.html#AEN78
+const (
+	NagiosOK       NagiosState = 0
+	NagiosWarning  NagiosState = 1
+	NagiosCritical NagiosState = 2
+	NagiosUnknown  NagiosState = 3
+)
+
+func (s NagiosState) String() string {
+	switch s {
+	case NagiosOK:
+		return "OK"
+	case NagiosWarning:
+		return "WARNING"
+	case NagiosCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// worse returns true if s is more serious than t, in order of OK, WARNING, UNKNOWN and CRITICAL.
+func (s NagiosState) worse(t NagiosState) bool {
+	rank := map[NagiosState]int{NagiosOK: 0, NagiosWarning: 1, NagiosUnknown: 2, NagiosCritical: 3}
+	return rank[s] > rank[t]
+}
+
+// nagiosState returns the state of the table.
+// Silenced tables are OK because they should not alert.
+func nagiosState(r config.FreshnessResult) NagiosState {
+	if r.Status == config.StatusError {
+		return NagiosUnknown
+	}
+	if len(r.Violations) == 0 || r.Silenced() {
+		return NagiosOK
+	}
+	if r.HighestSeverity() == config.SeverityCritical {
+		return NagiosCritical
+	}
+	return NagiosWarning
+}
+
+// WriteNagios writes the report in the format of Nagios plugins, and returns the state of the check.
+// The first line is the state with the number of tables for each state, followed by a line for each table.
+// The performance data has the age of each table in seconds, with duration thresholds as warning and critical levels.
+func WriteNagios(w io.Writer, report config.Report) (NagiosState, error) {
+	state := NagiosOK
+	counts := make(map[NagiosState]int)
+	silenced := 0
+	details := make([]string, 0, len(report.Results))
+	perfdata := make([]string, 0, len(report.Results))
+	for _, r := range report.Results {
+		s := nagiosState(r)
+		counts[s]++
+		if s.worse(state) {
+			state = s
+		}
+
+		detail := fmt.Sprintf("[%s] %s", s, r.FullTableID())
+		if len(r.Reason) > 0 {
+			detail += ": " + strings.Join(r.Reason, "; ")
+		}
+		if r.Silenced() {
+			silenced++
+			detail += fmt.Sprintf(" (silenced by %s)", r.SilencedBy)
+		}
+		details = append(details, detail)
+		perfdata = append(perfdata, nagiosPerfdata(r, report.CheckedAt))
+	}
+
+	summary := fmt.Sprintf("FRESHNESS %s - %d critical, %d warning, %d unknown, %d ok",
+		state, counts[NagiosCritical], counts[NagiosWarning], counts[NagiosUnknown], counts[NagiosOK])
+	if silenced > 0 {
+		summary += fmt.Sprintf(" (%d silenced)", silenced)
+	}
+
+	var b strings.Builder
+	b.WriteString(summary + "\n")
+	for _, d := range details {
+		// "|" separates the performance data
+		b.WriteString(strings.ReplaceAll(d, "|", "/") + "\n")
+	}
+	if len(perfdata) > 0 {
+		b.WriteString("| " + strings.Join(perfdata, " ") + "\n")
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return NagiosUnknown, err
+	}
+	return state, nil
+}
+
+// nagiosPerfdata returns the performance data of the age of the table in the form of 'label'=value[UOM];[warn];[crit].
+// The value is "U" if the age is unknown.
+func nagiosPerfdata(r config.FreshnessResult, checkedAt time.Time) string {
+	value := "U"
+	if !r.LastModifiedTime.IsZero() {
+		value = fmt.Sprintf("%.0fs", checkedAt.Sub(r.LastModifiedTime).Seconds())
+	}
+	seconds := func(d *config.DurationThreshold) string {
+		if d == nil {
+			return ""
+		}
+		return fmt.Sprintf("%.0f", d.Seconds())
+	}
+	label := strings.ReplaceAll(r.Key(), "'", "''")
+	return strings.TrimRight(fmt.Sprintf("'%s'=%s;%s;%s", label, value, seconds(r.WarningDurationThreshold), seconds(r.DurationThreshold)), ";")
+}
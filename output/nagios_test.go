@@ -0,0 +1,115 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var checkedAt = time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+
+var sampleReport = config.Report{
+	CheckedAt: checkedAt,
+	Results: []config.FreshnessResult{
+		{
+			Project:           "pj",
+			Dataset:           "ds",
+			ConfigTable:       "fresh",
+			TableID:           "fresh",
+			Status:            config.StatusFresh,
+			LastModifiedTime:  checkedAt.Add(-30 * time.Minute),
+			DurationThreshold: &config.DurationThreshold{Duration: time.Hour},
+		},
+		{
+			Project:                  "pj",
+			Dataset:                  "ds",
+			ConfigTable:              "sharded_",
+			TableID:                  "sharded_20200101",
+			Status:                   config.StatusStale,
+			LastModifiedTime:         checkedAt.Add(-90 * time.Minute),
+			DurationThreshold:        &config.DurationThreshold{Duration: 2 * time.Hour},
+			WarningDurationThreshold: &config.DurationThreshold{Duration: time.Hour},
+			Reason:                   []string{"not modified in 1h30m0s"},
+			Violations:               []config.Violation{{Rule: config.RuleWarningDurationThreshold, Reason: "not modified in 1h30m0s", Severity: config.SeverityWarning}},
+			Ownership:                config.Ownership{Owner: "data-platform"},
+		},
+		{
+			Project:     "pj",
+			Dataset:     "other",
+			ConfigTable: "missing",
+			TableID:     "missing",
+			Status:      config.StatusMissing,
+			Reason:      []string{"Table doesn't exist"},
+			Violations:  []config.Violation{{Rule: config.RuleExists, Reason: "Table doesn't exist", Severity: config.SeverityCritical}},
+		},
+		{
+			Project:     "pj",
+			Dataset:     "other",
+			ConfigTable: "error",
+			TableID:     "error",
+			Status:      config.StatusError,
+			Reason:      []string{"Failed to fetch metadata: forbidden"},
+		},
+	},
+}
+
+func TestWriteNagios(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []config.FreshnessResult
+		state   NagiosState
+		output  string
+	}{
+		{
+			name:    "all",
+			results: sampleReport.Results,
+			state:   NagiosCritical,
+			output: `FRESHNESS CRITICAL - 1 critical, 1 warning, 1 unknown, 1 ok
+[OK] pj.ds.fresh
+[WARNING] pj.ds.sharded_20200101: not modified in 1h30m0s
+[CRITICAL] pj.other.missing: Table doesn't exist
+[UNKNOWN] pj.other.error: Failed to fetch metadata: forbidden
+| 'pj.ds.fresh'=1800s;;3600 'pj.ds.sharded_'=5400s;3600;7200 'pj.other.missing'=U 'pj.other.error'=U
+`,
+		},
+		{
+			name:    "unknown is worse than warning",
+			results: []config.FreshnessResult{sampleReport.Results[1], sampleReport.Results[3]},
+			state:   NagiosUnknown,
+		},
+		{
+			name:    "warning",
+			results: sampleReport.Results[:2],
+			state:   NagiosWarning,
+		},
+		{
+			name: "silenced",
+			results: func() []config.FreshnessResult {
+				r := sampleReport.Results[2]
+				r.SilencedBy = "maintenance"
+				return []config.FreshnessResult{sampleReport.Results[0], r}
+			}(),
+			state: NagiosOK,
+			output: `FRESHNESS OK - 0 critical, 0 warning, 0 unknown, 2 ok (1 silenced)
+[OK] pj.ds.fresh
+[OK] pj.other.missing: Table doesn't exist (silenced by maintenance)
+| 'pj.ds.fresh'=1800s;;3600 'pj.other.missing'=U
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			state, err := WriteNagios(&b, config.Report{CheckedAt: checkedAt, Results: tt.results})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.state, state)
+			if tt.output != "" {
+				assert.Equal(t, tt.output, b.String())
+			}
+		})
+	}
+}
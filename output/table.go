@@ -0,0 +1,189 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hirosassa/tblmonit/config"
+	"golang.org/x/xerrors"
+)
+
+// TableOptions is options of the table format.
+type TableOptions struct {
+	SortBy string // "severity" (default) or "lag"
+	Color  bool   // colorize the output by ANSI escape sequences
+}
+
+// ANSI escape sequences of colors
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorGray   = "\x1b[90m"
+)
+
+var stateColors = map[string]string{
+	"fresh":    colorGreen,
+	"warning":  colorYellow,
+	"critical": colorRed,
+	"error":    colorRed,
+	"silenced": colorGray,
+}
+
+// stateRanks orders states by their urgency.
+var stateRanks = map[string]int{"critical": 0, "error": 1, "warning": 2, "silenced": 3, "fresh": 4}
+
+type tableRow struct {
+	result config.FreshnessResult
+	state  string
+	cells  []string
+}
+
+// lagLess returns true if a is older than b.
+// Missing tables are older than any table, and tables which failed to be checked are newer than any table.
+func lagLess(a, b config.FreshnessResult) bool {
+	rank := func(r config.FreshnessResult) int {
+		switch {
+		case r.Status == config.StatusError:
+			return 2
+		case r.LastModifiedTime.IsZero():
+			return 0
+		}
+		return 1
+	}
+	if rank(a) != rank(b) {
+		return rank(a) < rank(b)
+	}
+	return a.LastModifiedTime.Before(b.LastModifiedTime)
+}
+
+// WriteTable writes the report as aligned columns of table, status, last modified time, lag and thresholds.
+// Tables are grouped by project and dataset, and sorted by the urgency of their states or their lags.
+// Groups are also sorted by their first tables.
+// A footer with the number of tables for each status follows.
+func WriteTable(w io.Writer, report config.Report, opts TableOptions) error {
+	var less func(a, b tableRow) bool
+	switch opts.SortBy {
+	case "", "severity":
+		less = func(a, b tableRow) bool {
+			if stateRanks[a.state] != stateRanks[b.state] {
+				return stateRanks[a.state] < stateRanks[b.state]
+			}
+			return lagLess(a.result, b.result)
+		}
+	case "lag":
+		less = func(a, b tableRow) bool {
+			if lagLess(a.result, b.result) != lagLess(b.result, a.result) {
+				return lagLess(a.result, b.result)
+			}
+			return stateRanks[a.state] < stateRanks[b.state]
+		}
+	default:
+		return xerrors.Errorf("unknown sort key: %s", opts.SortBy)
+	}
+
+	paint := func(color, s string) string {
+		if !opts.Color {
+			return s
+		}
+		return color + s + colorReset
+	}
+
+	header := []string{"TABLE", "STATUS", "LAST MODIFIED", "LAG", "THRESHOLD"}
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+
+	groups := make([][]tableRow, 0)
+	index := make(map[string]int)
+	silenced := 0
+	for _, r := range report.Results {
+		state := resultState(r)
+		status := string(r.Status)
+		switch {
+		case state == "silenced":
+			status += " (silenced)"
+			silenced++
+		case state == "warning" || state == "critical":
+			status += fmt.Sprintf(" (%s)", r.HighestSeverity())
+		}
+		lastModified, lag := "-", "-"
+		if !r.LastModifiedTime.IsZero() {
+			lastModified = r.LastModifiedTime.Format("2006-01-02 15:04:05")
+			lag = formatLag(report.CheckedAt.Sub(r.LastModifiedTime))
+		}
+		threshold := strings.Join(thresholds(r), ", ")
+		if threshold == "" {
+			threshold = "-"
+		}
+
+		row := tableRow{result: r, state: state, cells: []string{r.TableID, status, lastModified, lag, threshold}}
+		for i, c := range row.cells {
+			if n := utf8.RuneCountInString(c); n > widths[i] {
+				widths[i] = n
+			}
+		}
+
+		key := r.Project + "." + r.Dataset
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], row)
+	}
+
+	for _, g := range groups {
+		sort.SliceStable(g, func(i, j int) bool { return less(g[i], g[j]) })
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return less(groups[i][0], groups[j][0]) })
+
+	// cells are padded before they are colored, so that escape sequences don't break the alignment
+	line := func(cells []string, color func(i int, s string) string) string {
+		padded := make([]string, len(cells))
+		for i, c := range cells {
+			if i < len(cells)-1 {
+				c += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c))
+			}
+			padded[i] = color(i, c)
+		}
+		return "  " + strings.Join(padded, "  ") + "\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(line(header, func(i int, s string) string { return paint(colorBold, s) }))
+	for _, g := range groups {
+		first := g[0].result
+		fmt.Fprintf(&b, "\n%s\n", paint(colorBold, first.Project+"."+first.Dataset))
+		for _, row := range g {
+			b.WriteString(line(row.cells, func(i int, s string) string {
+				if i == 1 {
+					return paint(stateColors[row.state], s)
+				}
+				return s
+			}))
+		}
+	}
+
+	counts := report.Counts()
+	fmt.Fprintf(&b, "\n%d tables: %s, %s, %s, %s",
+		len(report.Results),
+		paint(colorGreen, fmt.Sprintf("%d fresh", counts[config.StatusFresh])),
+		paint(colorRed, fmt.Sprintf("%d stale", counts[config.StatusStale])),
+		paint(colorRed, fmt.Sprintf("%d missing", counts[config.StatusMissing])),
+		paint(colorRed, fmt.Sprintf("%d error", counts[config.StatusError])))
+	if silenced > 0 {
+		fmt.Fprintf(&b, " (%d silenced)", silenced)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,81 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosassa/tblmonit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTable(t *testing.T) {
+	older := sampleReport.Results[0]
+	older.ConfigTable, older.TableID, older.LastModifiedTime = "older", "older", checkedAt.Add(-3*time.Hour)
+	older.DurationThreshold = nil
+	report := sampleReport
+	report.Results = append(append([]config.FreshnessResult{}, sampleReport.Results...), older)
+
+	tests := []struct {
+		name    string
+		opts    TableOptions
+		results []config.FreshnessResult
+		want    string
+	}{
+		{
+			name:    "severity",
+			opts:    TableOptions{},
+			results: report.Results,
+			want: `  TABLE             STATUS              LAST MODIFIED        LAG    THRESHOLD
+
+pj.other
+  missing           missing (critical)  -                    -      -
+  error             error               -                    -      -
+
+pj.ds
+  sharded_20200101  stale (warning)     2020-01-02 07:30:00  1h30m  every 2h0m, warn every 1h0m
+  older             fresh               2020-01-02 06:00:00  3h0m   -
+  fresh             fresh               2020-01-02 08:30:00  30m    every 1h0m
+
+5 tables: 2 fresh, 1 stale, 1 missing, 1 error
+`,
+		},
+		{
+			name:    "lag",
+			opts:    TableOptions{SortBy: "lag"},
+			results: []config.FreshnessResult{report.Results[0], report.Results[1], older},
+			want: `  TABLE             STATUS           LAST MODIFIED        LAG    THRESHOLD
+
+pj.ds
+  older             fresh            2020-01-02 06:00:00  3h0m   -
+  sharded_20200101  stale (warning)  2020-01-02 07:30:00  1h30m  every 2h0m, warn every 1h0m
+  fresh             fresh            2020-01-02 08:30:00  30m    every 1h0m
+
+3 tables: 2 fresh, 1 stale, 0 missing, 0 error
+`,
+		},
+		{
+			name: "color",
+			opts: TableOptions{Color: true},
+			results: func() []config.FreshnessResult {
+				r := report.Results[2]
+				r.SilencedBy = "maintenance"
+				return []config.FreshnessResult{r}
+			}(),
+			want: "  \x1b[1mTABLE  \x1b[0m  \x1b[1mSTATUS            \x1b[0m  \x1b[1mLAST MODIFIED\x1b[0m  \x1b[1mLAG\x1b[0m  \x1b[1mTHRESHOLD\x1b[0m\n" +
+				"\n\x1b[1mpj.other\x1b[0m\n" +
+				"  missing  \x1b[90mmissing (silenced)\x1b[0m  -              -    -\n" +
+				"\n1 tables: \x1b[32m0 fresh\x1b[0m, \x1b[31m0 stale\x1b[0m, \x1b[31m1 missing\x1b[0m, \x1b[31m0 error\x1b[0m (1 silenced)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			assert.NoError(t, WriteTable(&b, config.Report{CheckedAt: checkedAt, Results: tt.results}, tt.opts))
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+
+	assert.Error(t, WriteTable(&strings.Builder{}, report, TableOptions{SortBy: "name"}))
+}
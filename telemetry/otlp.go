@@ -0,0 +1,223 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultServiceName = "tblmonit"
+
+// Config is a configuration of OpenTelemetry on the settings file.
+type Config struct {
+	Endpoint    string            // base URL of OTLP/HTTP receiver such as http://localhost:4318, telemetry is disabled if empty
+	Headers     map[string]string // headers of requests, environment variables are expanded
+	ServiceName string            // service.name of the resource (default: tblmonit)
+}
+
+// Enabled returns true if telemetry should be exported.
+func (c Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// Exporter sends telemetry to OTLP/HTTP receiver in JSON encoding.
+type Exporter struct {
+	config Config
+	client *http.Client
+}
+
+// NewExporter returns Exporter configured by c.
+func NewExporter(c Config) *Exporter {
+	return &Exporter{config: c, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// types in OTLP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpResource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+// KeyValue is an attribute in OTLP JSON encoding.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is a value of an attribute in OTLP JSON encoding.
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 is encoded as a string
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0: unset, 2: error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+// KeyValues returns attributes in OTLP JSON encoding.
+func KeyValues(attrs []Attribute) []KeyValue {
+	kvs := make([]KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v AnyValue
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int64:
+			s := fmt.Sprint(value)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		kvs = append(kvs, KeyValue{Key: a.Key, Value: v})
+	}
+	return kvs
+}
+
+// UnixNano returns t in nanoseconds in OTLP JSON encoding.
+func UnixNano(t time.Time) string {
+	return fmt.Sprint(t.UnixNano())
+}
+
+func (e *Exporter) resource() otlpResource {
+	name := e.config.ServiceName
+	if name == "" {
+		name = defaultServiceName
+	}
+	return otlpResource{Attributes: KeyValues([]Attribute{String("service.name", name)})}
+}
+
+func (e *Exporter) scope() otlpScope {
+	return otlpScope{Name: "github.com/hirosassa/tblmonit"}
+}
+
+// ExportSpans sends the spans to /v1/traces of the receiver.
+func (e *Exporter) ExportSpans(ctx context.Context, spans []Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	ss := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: UnixNano(s.Start),
+			EndTimeUnixNano:   UnixNano(s.End),
+			Attributes:        KeyValues(s.Attributes),
+		}
+		if s.ParentSpanID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.ParentSpanID[:])
+		}
+		if s.Err != nil {
+			span.Status = otlpStatus{Code: 2, Message: s.Err.Error()}
+		}
+		ss = append(ss, span)
+	}
+
+	return e.post(ctx, "/v1/traces", otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource(),
+		ScopeSpans: []otlpScopeSpans{{Scope: e.scope(), Spans: ss}},
+	}}})
+}
+
+type otlpMetrics struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []interface{} `json:"metrics"`
+}
+
+// ExportMetrics sends the metrics to /v1/metrics of the receiver.
+// Each metric should be a metric in OTLP JSON encoding.
+func (e *Exporter) ExportMetrics(ctx context.Context, metrics []interface{}) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	return e.post(ctx, "/v1/metrics", otlpMetrics{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     e.resource(),
+		ScopeMetrics: []otlpScopeMetrics{{Scope: e.scope(), Metrics: metrics}},
+	}}})
+}
+
+func (e *Exporter) post(ctx context.Context, path string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return xerrors.Errorf("failed to encode telemetry: %w", err)
+	}
+
+	u := strings.TrimSuffix(e.config.Endpoint, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return xerrors.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return xerrors.Errorf("failed to send telemetry to %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return xerrors.Errorf("unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+)
+
+func TestStart(t *testing.T) {
+	ctx, span := Start(context.Background(), "no tracer", SpanKindInternal)
+	assert.Nil(t, span)
+	span.SetAttributes(String("key", "value"))
+	span.RecordError(xerrors.New("error"))
+	span.Finish()
+	assert.Equal(t, context.Background(), ctx)
+
+	tracer := &Tracer{}
+	ctx, root := Start(ContextWithTracer(context.Background(), tracer), "root", SpanKindInternal)
+	_, child := Start(ctx, "child", SpanKindClient, String("bigquery.table", "t"))
+	child.RecordError(xerrors.New("not found"))
+	child.Finish()
+	root.Finish()
+
+	spans := tracer.Flush()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, root.TraceID, spans[0].TraceID)
+	assert.Equal(t, root.SpanID, spans[0].ParentSpanID)
+	assert.EqualError(t, spans[0].Err, "not found")
+	assert.Equal(t, [8]byte{}, spans[1].ParentSpanID)
+	assert.Empty(t, tracer.Flush())
+}
+
+func TestExporter_ExportSpans(t *testing.T) {
+	os.Setenv("TBLMONIT_TEST_OTLP_TOKEN", "secret")
+	defer os.Unsetenv("TBLMONIT_TEST_OTLP_TOKEN")
+
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer srv.Close()
+
+	tracer := &Tracer{}
+	ctx, root := Start(ContextWithTracer(context.Background(), tracer), "root", SpanKindInternal)
+	_, child := Start(ctx, "child", SpanKindClient, Int("count", 3))
+	child.RecordError(xerrors.New("failed"))
+	child.Finish()
+	root.Finish()
+
+	e := NewExporter(Config{Endpoint: srv.URL + "/", Headers: map[string]string{"Authorization": "Bearer ${TBLMONIT_TEST_OTLP_TOKEN}"}})
+	assert.NoError(t, e.ExportSpans(context.Background(), tracer.Flush()))
+
+	rs := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"attributes": []interface{}{
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "tblmonit"}},
+	}}, rs["resource"])
+	spans := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	assert.Len(t, spans, 2)
+	c, r := spans[0].(map[string]interface{}), spans[1].(map[string]interface{})
+	assert.Equal(t, r["spanId"], c["parentSpanId"])
+	assert.Equal(t, r["traceId"], c["traceId"])
+	assert.Len(t, c["traceId"], 32)
+	assert.NotContains(t, r, "parentSpanId")
+	assert.Equal(t, float64(SpanKindClient), c["kind"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "count", "value": map[string]interface{}{"intValue": "3"}}}, c["attributes"])
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "failed"}, c["status"])
+	assert.Equal(t, map[string]interface{}{"code": float64(0)}, r["status"])
+}
+
+func TestExporter_ExportSpansError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e := NewExporter(Config{Endpoint: srv.URL})
+	assert.NoError(t, e.ExportSpans(context.Background(), nil))
+	assert.Error(t, e.ExportSpans(context.Background(), []Span{{Name: "span"}}))
+}
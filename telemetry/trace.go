@@ -0,0 +1,125 @@
+// Package telemetry records traces of checks, and exports them and metrics by OTLP/HTTP.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// SpanKind is a kind of a span.
+type SpanKind int
+
+// Span kinds defined by OTLP.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute is a key-value pair attached to spans and metrics.
+// Value should be string, bool, int64 or float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Span is a timed operation such as a call of BigQuery API.
+// Methods of nil Span do nothing, so that code can be instrumented whether tracing is enabled or not.
+type Span struct {
+	TraceID      [16]byte
+	SpanID       [8]byte
+	ParentSpanID [8]byte // zero for a root span
+	Name         string
+	Kind         SpanKind
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	Err          error
+
+	tracer *Tracer
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+// RecordError marks the span as failed by err if it is not nil.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Err = err
+}
+
+// Finish ends the span, and records it on the tracer.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	s.tracer.record(*s)
+}
+
+// Tracer records finished spans until they are exported.
+type Tracer struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (t *Tracer) record(s Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, s)
+}
+
+// Flush returns finished spans, and removes them from the tracer.
+func (t *Tracer) Flush() []Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := t.spans
+	t.spans = nil
+	return spans
+}
+
+type tracerKey struct{}
+
+type spanKey struct{}
+
+// ContextWithTracer returns a context in which spans are recorded on the tracer.
+func ContextWithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Start starts a span as a child of the span in ctx, and returns a context with the new span.
+// It returns nil Span if ctx has no tracer.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	t, ok := ctx.Value(tracerKey{}).(*Tracer)
+	if !ok || t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{Name: name, Kind: kind, Start: time.Now(), Attributes: attrs, tracer: t}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.TraceID = parent.TraceID
+		s.ParentSpanID = parent.SpanID
+	} else {
+		rand.Read(s.TraceID[:])
+	}
+	rand.Read(s.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}